@@ -0,0 +1,181 @@
+// Package analysis computes robust statistics of the samples of a run. A few samples are usually
+// far from the rest, because of a garbage collection or a noisy neighbour on the host, so the
+// median and spread of the samples are reported along with the mean, and outliers are flagged.
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// Trim is the proportion of the lowest and of the highest values left out of a trimmed mean
+	Trim = 0.1
+	// Fence is how many interquartile ranges beyond the quartiles a value is an outlier
+	Fence = 1.5
+	// Confidence is the confidence level of bootstrap confidence intervals
+	Confidence = 0.95
+	// Resamples is how many times values are resampled to bootstrap a confidence interval
+	Resamples = 1000
+	// seed keeps bootstrapped intervals the same each time the same values are summarized
+	seed = 1
+)
+
+// Interval is a confidence interval of a statistic
+type Interval struct {
+	Lower      float64 `json:"lower"`
+	Upper      float64 `json:"upper"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Summary are the robust statistics of a set of values. Outliers are flagged by their index in
+// the values, and are not left out of any statistic.
+type Summary struct {
+	Samples     int      `json:"samples"`
+	Mean        float64  `json:"mean"`
+	Median      float64  `json:"median"`
+	MAD         float64  `json:"mad"`
+	TrimmedMean float64  `json:"trimmed-mean"`
+	Q1          float64  `json:"q1"`
+	Q3          float64  `json:"q3"`
+	P95         float64  `json:"p95"`
+	Max         float64  `json:"max"`
+	IQR         float64  `json:"iqr"`
+	LowerFence  float64  `json:"lower-fence"`
+	UpperFence  float64  `json:"upper-fence"`
+	MeanCI      Interval `json:"mean-ci"`
+	MedianCI    Interval `json:"median-ci"`
+	Outliers    []int    `json:"outliers"`
+}
+
+// Summarize calculates the robust statistics of values, an empty set of values has none
+func Summarize(values []float64) Summary {
+	summary := Summary{Samples: len(values), Outliers: []int{}}
+	if len(values) == 0 {
+		return summary
+	}
+
+	sorted := sortedCopy(values)
+	summary.Mean = Mean(values)
+	summary.Median = Quantile(sorted, 0.5)
+	summary.MAD = MAD(values)
+	summary.TrimmedMean = TrimmedMean(values, Trim)
+	summary.Q1 = Quantile(sorted, 0.25)
+	summary.Q3 = Quantile(sorted, 0.75)
+	summary.P95 = Quantile(sorted, 0.95)
+	summary.Max = sorted[len(sorted)-1]
+	summary.IQR = summary.Q3 - summary.Q1
+	summary.LowerFence, summary.UpperFence, summary.Outliers = Outliers(values, Fence)
+
+	rng := rand.New(rand.NewSource(seed))
+	summary.MeanCI = Bootstrap(values, Mean, Resamples, Confidence, rng)
+	summary.MedianCI = Bootstrap(values, Median, Resamples, Confidence, rng)
+	return summary
+}
+
+// Mean is the arithmetic mean of values
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// Median is the middle of values, or the mean of the two middle values when there is an even
+// number of them
+func Median(values []float64) float64 {
+	return Quantile(sortedCopy(values), 0.5)
+}
+
+// MAD is the median absolute deviation of values from their median
+func MAD(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	median := Median(values)
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value - median)
+	}
+	return Median(deviations)
+}
+
+// TrimmedMean is the mean of values without the lowest and the highest trim proportion of them
+func TrimmedMean(values []float64, trim float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sorted := sortedCopy(values)
+	cut := int(math.Floor(float64(len(sorted)) * trim))
+	if 2*cut >= len(sorted) {
+		return Quantile(sorted, 0.5)
+	}
+	return Mean(sorted[cut : len(sorted)-cut])
+}
+
+// Quantile interpolates between the closest ranks of sorted values, q is between 0 and 1
+func Quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := q * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// Outliers flags the values that are more than fence interquartile ranges below the first
+// quartile or above the third, and returns the fences along with the index of each outlier
+func Outliers(values []float64, fence float64) (lower, upper float64, outliers []int) {
+	outliers = []int{}
+	if len(values) == 0 {
+		return math.NaN(), math.NaN(), outliers
+	}
+
+	sorted := sortedCopy(values)
+	q1, q3 := Quantile(sorted, 0.25), Quantile(sorted, 0.75)
+	lower = q1 - fence*(q3-q1)
+	upper = q3 + fence*(q3-q1)
+	for i, value := range values {
+		if value < lower || value > upper {
+			outliers = append(outliers, i)
+		}
+	}
+	return lower, upper, outliers
+}
+
+// Bootstrap estimates a confidence interval of a statistic of values, by calculating it for
+// resamples of values drawn with replacement and taking the percentiles of the results
+func Bootstrap(values []float64, statistic func([]float64) float64, resamples int, confidence float64, rng *rand.Rand) Interval {
+	interval := Interval{Confidence: confidence}
+	if len(values) == 0 || resamples <= 0 {
+		interval.Lower, interval.Upper = math.NaN(), math.NaN()
+		return interval
+	}
+
+	estimates := make([]float64, resamples)
+	resample := make([]float64, len(values))
+	for i := range estimates {
+		for j := range resample {
+			resample[j] = values[rng.Intn(len(values))]
+		}
+		estimates[i] = statistic(resample)
+	}
+	sort.Float64s(estimates)
+
+	interval.Lower = Quantile(estimates, (1-confidence)/2)
+	interval.Upper = Quantile(estimates, (1+confidence)/2)
+	return interval
+}
+
+func sortedCopy(values []float64) []float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	return sorted
+}
@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRobustStatistics(t *testing.T) {
+	values := []float64{12, 10, 11, 13, 10, 12, 11, 95, 12, 11}
+
+	if median := Median(values); median != 11.5 {
+		t.Errorf("expected a median of 11.5, got %v", median)
+	}
+	if mad := MAD(values); mad != 0.5 {
+		t.Errorf("expected a median absolute deviation of 0.5, got %v", mad)
+	}
+	// the lowest and highest value are left out
+	if mean := TrimmedMean(values, 0.1); mean != 11.5 {
+		t.Errorf("expected a trimmed mean of 11.5, got %v", mean)
+	}
+	if mean := Mean(values); mean != 19.7 {
+		t.Errorf("expected the mean to include the outlier, got %v", mean)
+	}
+
+	lower, upper, outliers := Outliers(values, Fence)
+	if !reflect.DeepEqual(outliers, []int{7}) {
+		t.Errorf("expected only the value at index 7 to be an outlier, got %v", outliers)
+	}
+	if lower != 9.5 || upper != 13.5 {
+		t.Errorf("expected fences of 9.5 and 13.5, got %v and %v", lower, upper)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		q        float64
+		expected float64
+	}{
+		{0, 1},
+		{0.5, 5.5},
+		{0.95, 9.55},
+		{1, 10},
+	}
+	for _, test := range tests {
+		value := Quantile(values, test.q)
+		if math.Abs(value-test.expected) > 1e-9 {
+			t.Errorf("expected the %g quantile to be %g, got %g", test.q, test.expected, value)
+		}
+	}
+	if !math.IsNaN(Quantile(nil, 0.5)) {
+		t.Error("expected the quantile of no values to be NaN")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	values := []float64{12, 10, 11, 13, 10, 12, 11, 95, 12, 11}
+	summary := Summarize(values)
+	if summary.Samples != 10 || summary.Median != 11.5 || summary.IQR != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if !reflect.DeepEqual(summary.Outliers, []int{7}) {
+		t.Errorf("expected the outlier to be flagged, got %v", summary.Outliers)
+	}
+	if summary.MedianCI.Lower > summary.Median || summary.MedianCI.Upper < summary.Median {
+		t.Errorf("expected the median %v to be within its confidence interval %+v", summary.Median, summary.MedianCI)
+	}
+	if summary.MeanCI.Confidence != Confidence {
+		t.Errorf("expected a confidence of %v, got %v", Confidence, summary.MeanCI.Confidence)
+	}
+
+	// bootstrapped intervals are the same every time
+	if again := Summarize(values); !reflect.DeepEqual(summary, again) {
+		t.Errorf("expected the same summary twice, got %+v and %+v", summary, again)
+	}
+
+	empty := Summarize(nil)
+	if empty.Samples != 0 || empty.Mean != 0 || len(empty.Outliers) != 0 {
+		t.Errorf("expected an empty summary, got %+v", empty)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	values := make([]float64, 200)
+	for i := range values {
+		values[i] = 50 + rng.NormFloat64()*5
+	}
+
+	interval := Bootstrap(values, Mean, Resamples, Confidence, rand.New(rand.NewSource(seed)))
+	mean := Mean(values)
+	if interval.Lower >= mean || interval.Upper <= mean {
+		t.Errorf("expected the mean %v to be within %+v", mean, interval)
+	}
+	// the standard error of the mean is about 5/sqrt(200), so the interval is about 1.4 wide
+	if width := interval.Upper - interval.Lower; width < 0.8 || width > 2 {
+		t.Errorf("expected an interval about 1.4 wide, got %v", width)
+	}
+
+	constant := Bootstrap([]float64{3, 3, 3}, Median, 10, Confidence, rand.New(rand.NewSource(seed)))
+	if constant.Lower != 3 || constant.Upper != 3 {
+		t.Errorf("expected constant values to have no spread, got %+v", constant)
+	}
+	if empty := Bootstrap(nil, Mean, 10, Confidence, rng); !math.IsNaN(empty.Lower) {
+		t.Errorf("expected no interval without values, got %+v", empty)
+	}
+}
@@ -0,0 +1,292 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	cgroupRoot = "/sys/fs/cgroup"
+	procRoot   = "/proc"
+)
+
+var (
+	errCgroupNotLinux = errors.New("the cgroup collector can only be used on linux hosts where the docker engine runs natively")
+	errNotCgroupV2    = errors.New("the cgroup collector requires a host using cgroup v2")
+)
+
+// cgroupSampler reads the resource usage of a container straight from its cgroup v2
+// directory. Each sample is read on demand, allowing much finer sampling than the
+// docker stats api.
+type cgroupSampler struct {
+	dir      string
+	pid      int
+	cpus     uint32
+	start    time.Time
+	failures int
+	err      error
+}
+
+// sampleCgroup locates the cgroup v2 directory of a running container
+func sampleCgroup(cli *client.Client, containerID string) (*cgroupSampler, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errCgroupNotLinux
+	}
+
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	if err != nil {
+		return nil, errNotCgroupV2
+	}
+
+	info, err := cli.ContainerInspect(context.Background(), containerID)
+	if err != nil {
+		return nil, err
+	}
+	if info.State == nil || info.State.Pid == 0 {
+		return nil, fmt.Errorf("container %s is not running", containerID)
+	}
+
+	f, err := os.Open(filepath.Join(procRoot, strconv.Itoa(info.State.Pid), "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	group, err := parseCgroupPath(f)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(cgroupRoot, group)
+	log.Debug().Msgf("sampling container %s from cgroup %s", containerID, dir)
+	return &cgroupSampler{
+		dir:   dir,
+		pid:   info.State.Pid,
+		cpus:  uint32(runtime.NumCPU()),
+		start: time.Now(),
+	}, nil
+}
+
+// Latest reads a new sample from the cgroup of the container
+func (c *cgroupSampler) Latest() (statSample, bool) {
+	if c.err != nil {
+		return statSample{}, false
+	}
+
+	sample, err := c.read()
+	if err != nil {
+		c.failures++
+		if c.failures > maxStatsRetries {
+			c.err = fmt.Errorf("unable to read cgroup %s after %d attempts: %v", c.dir, maxStatsRetries, err)
+			return statSample{}, false
+		}
+
+		log.Debug().Msgf("error reading cgroup %s (%d/%d): %v", c.dir, c.failures, maxStatsRetries, err)
+		return statSample{}, false
+	}
+
+	c.failures = 0
+	return sample, true
+}
+
+func (c *cgroupSampler) Err() error {
+	return c.err
+}
+
+// Close is a no-op, the cgroup sampler holds no open resources between reads
+func (c *cgroupSampler) Close() {}
+
+func (c *cgroupSampler) read() (statSample, error) {
+	sample := statSample{}
+	stats := &sample.Stats
+
+	cpu, err := readFlatKeyed(filepath.Join(c.dir, "cpu.stat"))
+	if err != nil {
+		return sample, err
+	}
+
+	memory, err := readFlatKeyed(filepath.Join(c.dir, "memory.stat"))
+	if err != nil {
+		return sample, err
+	}
+
+	current, err := readSingleValue(filepath.Join(c.dir, "memory.current"))
+	if err != nil {
+		return sample, err
+	}
+
+	disk, err := readIOStat(filepath.Join(c.dir, "io.stat"))
+	if err != nil {
+		return sample, err
+	}
+
+	network, err := readNetDev(filepath.Join(procRoot, strconv.Itoa(c.pid), "net", "dev"))
+	if err != nil {
+		return sample, err
+	}
+
+	sample.Read = time.Now()
+
+	// the system usage is the wall clock time across all cpus, so that cpu utilization
+	// is calculated against the real time that passed between two samples
+	stats.CPUStats.CPUUsage.TotalUsage = cpu["usage_usec"] * 1000
+	stats.CPUStats.SystemUsage = uint64(sample.Read.Sub(c.start).Nanoseconds()) * uint64(c.cpus)
+	stats.CPUStats.OnlineCPUs = c.cpus
+	stats.MemoryStats.Usage = current
+	stats.MemoryStats.Stats = memory
+	stats.StorageStats.ReadSizeBytes = disk["rbytes"]
+	stats.StorageStats.WriteSizeBytes = disk["wbytes"]
+	stats.StorageStats.ReadCountNormalized = disk["rios"]
+	stats.StorageStats.WriteCountNormalized = disk["wios"]
+	stats.Networks = network
+
+	return sample, nil
+}
+
+// parseCgroupPath finds the unified hierarchy entry of a /proc/<pid>/cgroup file
+func parseCgroupPath(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errNotCgroupV2
+}
+
+func readSingleValue(file string) (uint64, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+func readFlatKeyed(file string) (map[string]uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseFlatKeyed(f)
+}
+
+// parseFlatKeyed parses cgroup files made of "key value" lines, like cpu.stat and memory.stat
+func parseFlatKeyed(r io.Reader) (map[string]uint64, error) {
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for cgroup key %s: %v", fields[0], err)
+		}
+		values[fields[0]] = value
+	}
+	return values, scanner.Err()
+}
+
+func readIOStat(file string) (map[string]uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseIOStat(f)
+}
+
+// parseIOStat sums the nested keyed values of io.stat across all devices
+func parseIOStat(r io.Reader) (map[string]uint64, error) {
+	totals := map[string]uint64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		// the first field is the major:minor number of the device
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for io.stat key %s: %v", key, err)
+			}
+			totals[key] += n
+		}
+	}
+	return totals, scanner.Err()
+}
+
+func readNetDev(file string) (map[string]types.NetworkStats, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseNetDev(f)
+}
+
+// parseNetDev reads the network interface counters in the network namespace of a process
+func parseNetDev(r io.Reader) (map[string]types.NetworkStats, error) {
+	networks := map[string]types.NetworkStats{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		name = strings.TrimSpace(name)
+		fields := strings.Fields(counters)
+		if name == "lo" || len(fields) < 16 {
+			continue
+		}
+
+		values := make([]uint64, 16)
+		for i := range values {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid counter for interface %s: %v", name, err)
+			}
+			values[i] = v
+		}
+
+		networks[name] = types.NetworkStats{
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		}
+	}
+	return networks, scanner.Err()
+}
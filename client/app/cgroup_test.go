@@ -0,0 +1,63 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCgroupPath(t *testing.T) {
+	procCgroup := "0::/system.slice/docker-0123456789ab.scope\n"
+	path, err := parseCgroupPath(strings.NewReader(procCgroup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/system.slice/docker-0123456789ab.scope" {
+		t.Errorf("Incorrect cgroup path parsed: got \"%s\"", path)
+	}
+
+	_, err = parseCgroupPath(strings.NewReader("12:memory:/docker/0123456789ab\n"))
+	if err != errNotCgroupV2 {
+		t.Errorf("Expected a cgroup v1 hierarchy to be rejected, got: %v", err)
+	}
+}
+
+func TestParseCgroupFiles(t *testing.T) {
+	cpuStat := `usage_usec 2500
+user_usec 2000
+system_usec 500
+`
+	cpu, err := parseFlatKeyed(strings.NewReader(cpuStat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu["usage_usec"] != 2500 || cpu["system_usec"] != 500 {
+		t.Errorf("Incorrect cpu.stat values parsed: %v", cpu)
+	}
+
+	ioStat := `8:0 rbytes=1024 wbytes=4096 rios=1 wios=2 dbytes=0 dios=0
+8:16 rbytes=0 wbytes=1024 rios=0 wios=1 dbytes=0 dios=0
+`
+	disk, err := parseIOStat(strings.NewReader(ioStat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disk["wbytes"] != 5120 || disk["wios"] != 3 || disk["rbytes"] != 1024 {
+		t.Errorf("Incorrect io.stat totals parsed: %v", disk)
+	}
+
+	netDev := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     100       1    0    0    0     0          0         0      100       1    0    0    0     0       0          0
+  eth0:    2048      10    0    0    0     0          0         0     4096      20    0    0    0     0       0          0
+`
+	networks, err := parseNetDev(strings.NewReader(netDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := networks["lo"]; ok {
+		t.Error("The loopback interface should not be counted")
+	}
+	if networks["eth0"].TxBytes != 4096 || networks["eth0"].RxBytes != 2048 {
+		t.Errorf("Incorrect interface counters parsed: %+v", networks["eth0"])
+	}
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 
 	"github.com/rs/zerolog/log"
@@ -10,12 +12,19 @@ import (
 type DockerCompose struct {
 	Version  string             `yaml:"version"`
 	Services map[string]Service `yaml:"services"`
+
+	// secrets are KEY=VALUE pairs written to an env_file next to the compose file
+	secrets []string
 }
 
 type Service struct {
 	Image       string   `yaml:"image"`
+	Command     []string `yaml:"command,omitempty"`
 	Ports       []string `yaml:"ports,omitempty"`
 	DependsOn   []string `yaml:"depends_on,omitempty"`
+	EnvFile     []string `yaml:"env_file,omitempty"`
+	Volumes     []string `yaml:"volumes,omitempty"`
+	ExtraHosts  []string `yaml:"extra_hosts,omitempty"`
 	Environment []string `yaml:"environment"`
 }
 
@@ -52,5 +61,19 @@ func (compose *DockerCompose) WriteFile(name, workspace string) (JobDirectory, e
 	f.Close()
 
 	log.Debug().Msgf("content written to compose file successfully")
+
+	// compose would still interpolate the compose file with the secrets an older version wrote
+	err = os.Remove(jobDir.GetLegacySecretsFile())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	if len(compose.secrets) > 0 {
+		err = writeSecretsFile(jobDir.GetSecretsFile(), compose.secrets)
+		if err != nil {
+			return "", err
+		}
+		log.Debug().Msgf("wrote secrets to %s", jobDir.GetSecretsFile())
+	}
 	return jobDir, nil
 }
@@ -1,11 +1,10 @@
 package app
 
 import (
-	"agent-p/handle"
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -20,29 +19,63 @@ const (
 )
 
 type RunConfig struct {
-	Version            string `yaml:"version"`
-	Server             string `yaml:"new-relic-server"` // production, staging, eu
-	LicenseKey         string `yaml:"new-relic-license-key,omitempty"`
-	CollectionEndpoint string `yaml:",omitempty"` // New Relic Collection Endpoint
-	Runs               []Run  `yaml:"jobs"`
+	Version            string        `yaml:"version"`
+	Server             string        `yaml:"new-relic-server" enum:"production,staging,eu,local,custom"`
+	LicenseKey         string        `yaml:"new-relic-license-key,omitempty"`
+	CustomServer       *CustomServer `yaml:"custom-server,omitempty"`
+	CollectionEndpoint string        `yaml:"-"`                              // New Relic Collection Endpoint
+	LocalCollectorPort *uint         `yaml:"local-collector-port,omitempty"` // port the local collector listens on
+	OTLPReceiverPort   *uint         `yaml:"otlp-receiver-port,omitempty"`   // port the otlp receiver listens on
+	Runs               []Run         `yaml:"jobs"`
+
+	endpoint serverEndpoint
 }
 
 type Run struct {
-	Name          string `yaml:"name"`
+	Name         string `yaml:"name"`
+	Baseline     string `yaml:"baseline,omitempty"`      // name of the job this job is compared against
+	AgentVersion string `yaml:"agent-version,omitempty"` // version of the agent the app runs, labels the runs of the job
+	Exporter     string `yaml:"exporter,omitempty" enum:"newrelic,otlp"`
+	// Matrix expands a job into one job for each combination of its values, once expanded it
+	// holds the values of the job
+	Matrix        map[string]string `yaml:"matrix,omitempty"`
 	Data          `yaml:"data"`
 	App           `yaml:"app"`
 	TrafficDriver `yaml:"traffic-driver"`
 }
 
 type App struct {
-	Image   string            `yaml:"image"`
-	Port    *uint             `yaml:"service-port"`
-	EnvVars map[string]string `yaml:"environment-variables"`
+	Image          string            `yaml:"image"`
+	Port           *uint             `yaml:"service-port"`
+	EnvVars        map[string]string `yaml:"environment-variables"`
+	RuntimeMetrics *RuntimeMetrics   `yaml:"runtime-metrics,omitempty"`
+	Profiling      *Profiling        `yaml:"profiling,omitempty"`
+
+	// secretVars are the environment variables whose values were read from a secret reference
+	secretVars map[string]bool
+}
+
+// RuntimeMetrics is an http endpoint of the app exposing in process metrics, like the heap or gc
+type RuntimeMetrics struct {
+	Format  string   `yaml:"format" enum:"prometheus,expvar"`
+	Path    string   `yaml:"path,omitempty"`    // defaults to /metrics or /debug/vars
+	Port    *uint    `yaml:"port,omitempty"`    // defaults to the service-port
+	Metrics []string `yaml:"metrics,omitempty"` // defaults to the go runtime metrics of the format
+}
+
+// Profiling captures pprof profiles from a go app that serves net/http/pprof
+type Profiling struct {
+	Path        string `yaml:"path,omitempty"`         // defaults to /debug/pprof
+	Port        *uint  `yaml:"port,omitempty"`         // defaults to the service-port
+	CPUDuration string `yaml:"cpu-duration,omitempty"` // defaults to 30s
 }
 
 type Data struct {
 	SummaryStatistic bool   `yaml:"summary-statistics"`
 	Interval         string `yaml:"collection-interval"`
+	Collector        string `yaml:"collector,omitempty" enum:"docker,cgroup"`
+	Warmup           string `yaml:"warmup,omitempty"`   // a duration after traffic starts, or auto. defaults to 5s
+	Cooldown         string `yaml:"cooldown,omitempty"` // a duration before traffic stops. defaults to 3s
 }
 
 type TrafficDriver struct {
@@ -60,14 +93,11 @@ type Traffic struct {
 
 var (
 	errNameEmpty          = errors.New("run.name can not be empty")
-	errNoLicenseKey       = errors.New("a New Relic license key must be provided, either set the new-relic-license-key field in the config.yaml file or set the environment variable \"NEW_RELIC_LICENSE_KEY\"")
+	errNoLicenseKey       = errors.New("a New Relic license key must be provided, either set the new-relic-license-key field in the config.yaml file to a key, a ${VAR} reference, or a file:path reference, or set the environment variable \"NEW_RELIC_LICENSE_KEY\"")
 	errNoRuns             = errors.New("config error: run config must have at least one run")
-	errServerNotSupported = errors.New("config error: new-relic-server must be either: production, stagin, eu")
-	serverEndpoints       = map[string]string{
-		"production": "",
-		"staging":    "staging-collector.newrelic.com",
-		"eu":         "",
-	}
+	errServerNotSupported = errors.New("config error: new-relic-server must be either: production, staging, eu, local, custom")
+	errExporterInvalid    = errors.New("config error: run.exporter must be either: newrelic, otlp")
+	errCollectorInvalid   = errors.New("config error: data.collector must be either: docker, cgroup")
 )
 
 // Defaults
@@ -80,93 +110,318 @@ const (
 	interval    = 1
 )
 
-func (r *RunConfig) defaultAndValidate() error {
+// Duration fields
+const (
+	collectionIntervalField = "data.collection-interval"
+	startupDelayField       = "traffic-driver.startup-delay"
+	trafficDurationField    = "traffic-driver.traffic.duration"
+	cpuDurationField        = "app.profiling.cpu-duration"
+	warmupField             = "data.warmup"
+	cooldownField           = "data.cooldown"
+)
+
+// zeroDurations are the duration fields that can be zero
+var zeroDurations = map[string]bool{
+	startupDelayField: true,
+	warmupField:       true,
+	cooldownField:     true,
+}
+
+// autoWarmup detects when the app settles into a steady state instead of using a fixed warmup
+const autoWarmup = "auto"
+
+// Data collectors
+const (
+	dockerCollector = "docker"
+	cgroupCollector = "cgroup"
+)
+
+// jobError is a config error found in the job at an index of RunConfig.Runs
+type jobError struct {
+	job int
+	err error
+}
+
+func (e jobError) Error() string {
+	return e.err.Error()
+}
+
+func (e jobError) Unwrap() error {
+	return e.err
+}
+
+// fieldError is a config error in a field, written as a dotted path of yaml keys from the job it
+// is in, or from the top of the config for errors that are not in a job
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e fieldError) Error() string {
+	return e.err.Error()
+}
+
+func (e fieldError) Unwrap() error {
+	return e.err
+}
+
+// defaultAndValidate fills in the defaults of a config, and returns every problem it finds in it
+func (r *RunConfig) defaultAndValidate() []error {
 	if len(r.Runs) == 0 {
-		return errNoRuns
+		return []error{fieldError{"jobs", errNoRuns}}
 	}
 
-	endpoint, ok := serverEndpoints[strings.TrimSpace(strings.ToLower(r.Server))]
-	if !ok {
-		return errServerNotSupported
+	errs := []error{}
+	r.Server = strings.TrimSpace(strings.ToLower(r.Server))
+	if r.Server == customServer {
+		if r.CustomServer == nil {
+			errs = append(errs, fieldError{"new-relic-server", errCustomServerEmpty})
+		} else if serverErrs := r.CustomServer.defaultAndValidate(); len(serverErrs) > 0 {
+			errs = append(errs, serverErrs...)
+		} else {
+			r.endpoint = r.CustomServer.endpoint()
+		}
+	} else {
+		endpoint, ok := serverEndpoints[r.Server]
+		if !ok {
+			errs = append(errs, fieldError{"new-relic-server", errServerNotSupported})
+		}
+		if r.CustomServer != nil {
+			errs = append(errs, fieldError{"custom-server", errCustomServerUnused})
+		}
+		r.endpoint = endpoint
 	}
+	r.CollectionEndpoint = r.endpoint.Host
 
-	r.CollectionEndpoint = endpoint
-
-	if r.LicenseKey == "" {
-		key := os.Getenv("NEW_RELIC_LICENSE_KEY")
-		if key == "" {
-			return errNoLicenseKey
-		}
-		r.LicenseKey = key
+	if r.Server == localServer && r.LocalCollectorPort == nil {
+		r.LocalCollectorPort = UintPointer(defaultLocalCollectorPort)
+	}
+	if r.OTLPReceiverPort == nil {
+		r.OTLPReceiverPort = UintPointer(defaultOTLPReceiverPort)
 	}
 
 	seen := map[string]bool{}
 	for i := 0; i < len(r.Runs); i++ {
 		run := &r.Runs[i]
 		if seen[run.Name] {
-			return fmt.Errorf("job name %s already in use, please give each job a unique name", run.Name)
+			errs = append(errs, jobError{i, fieldError{"name", fmt.Errorf("job name %s already in use, please give each job a unique name", run.Name)}})
 		} else {
 			seen[run.Name] = true
 		}
 
-		err := run.defaultAndValidate()
+		for _, err := range run.defaultAndValidate() {
+			errs = append(errs, jobError{i, err})
+		}
+	}
+
+	for i, run := range r.Runs {
+		if run.Baseline == "" {
+			continue
+		}
+		if run.Baseline == run.Name {
+			errs = append(errs, jobError{i, fieldError{"baseline", fmt.Errorf("config error: job %s can not be its own baseline", run.Name)}})
+		} else if !seen[run.Baseline] {
+			errs = append(errs, jobError{i, fieldError{"baseline", fmt.Errorf("config error: baseline %s of job %s is not a job in this config", run.Baseline, run.Name)}})
+		}
+	}
+
+	// a license key is only needed when a job sends data to New Relic
+	for _, run := range r.Runs {
+		if run.Exporter == newRelicExporter {
+			if err := r.defaultLicenseKey(); err != nil {
+				errs = append(errs, fieldError{"new-relic-license-key", err})
+			}
+			break
+		}
+	}
+	return errs
+}
+
+func (r *RunConfig) defaultLicenseKey() error {
+	if r.LicenseKey == "" {
+		r.LicenseKey = os.Getenv(licenseKeyVar)
+	} else {
+		key, _, err := resolveSecret(r.LicenseKey)
 		if err != nil {
-			return err
+			return fmt.Errorf("config error: new-relic-license-key can not be resolved: %v", err)
+		}
+		r.LicenseKey = key
+	}
+	secrets.add(r.LicenseKey)
+
+	if r.LicenseKey == "" {
+		if r.Server != localServer {
+			return errNoLicenseKey
 		}
+		r.LicenseKey = localLicenseKey
 	}
 	return nil
 }
 
-func (r *Run) defaultAndValidate() error {
+// defaultAndValidate fills in the defaults of a job, and returns every problem in it
+func (r *Run) defaultAndValidate() []error {
 	if r.Name == "" {
-		return errNameEmpty
+		return []error{fieldError{"name", errNameEmpty}}
 	}
 
-	err := r.App.defaultAndValidate()
-	if err != nil {
-		return err
+	errs := []error{}
+	r.Exporter = strings.TrimSpace(strings.ToLower(r.Exporter))
+	if r.Exporter == "" {
+		r.Exporter = newRelicExporter
 	}
-
-	err = r.Data.defaultAndValidate()
-	if err != nil {
-		return err
+	if _, ok := exporterProfiles[r.Exporter]; !ok {
+		errs = append(errs, fieldError{"exporter", errExporterInvalid})
 	}
 
-	err = r.TrafficDriver.defaultAndValidate()
-	if err != nil {
-		return err
-	}
+	errs = append(errs, r.App.defaultAndValidate()...)
+	errs = append(errs, r.Data.defaultAndValidate()...)
+	errs = append(errs, r.TrafficDriver.defaultAndValidate()...)
 
-	return nil
+	// the timing of a job can only be checked once every duration in it is valid
+	if len(errs) == 0 {
+		_, err := r.timing()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
 }
 
-func (d *Data) defaultAndValidate() error {
+func (d *Data) defaultAndValidate() []error {
+	errs := []error{}
 	if d.Interval == "" {
 		d.Interval = intervalStr
 	} else {
-		interval, err := validateDuration(d.Interval)
+		interval, err := validateDuration(collectionIntervalField, d.Interval)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			d.Interval = interval
+		}
+	}
+
+	if strings.TrimSpace(strings.ToLower(d.Warmup)) == autoWarmup {
+		d.Warmup = autoWarmup
+	} else if d.Warmup != "" {
+		warmup, err := validateDuration(warmupField, d.Warmup)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			d.Warmup = warmup
+		}
+	}
+
+	if d.Cooldown != "" {
+		cooldown, err := validateDuration(cooldownField, d.Cooldown)
 		if err != nil {
-			return err
+			errs = append(errs, err)
+		} else {
+			d.Cooldown = cooldown
 		}
+	}
 
-		d.Interval = interval
+	d.Collector = strings.TrimSpace(strings.ToLower(d.Collector))
+	switch d.Collector {
+	case "":
+		d.Collector = dockerCollector
+	case dockerCollector, cgroupCollector:
+	default:
+		errs = append(errs, fieldError{"data.collector", errCollectorInvalid})
 	}
 
-	return nil
+	return errs
 }
 
 var (
-	errImageEmpty = errors.New("config error: run.app.image must be a valid container image")
+	errImageEmpty           = errors.New("config error: run.app.image must be a valid container image")
+	errRuntimeFormatInvalid = errors.New("config error: run.app.runtime-metrics.format must be either: prometheus, expvar")
+	errRuntimePortEmpty     = errors.New("config error: run.app.runtime-metrics.port must be set when the app has no service-port")
+	errProfilingPortEmpty   = errors.New("config error: run.app.profiling.port must be set when the app has no service-port")
 )
 
-func (a *App) defaultAndValidate() error {
+func (a *App) defaultAndValidate() []error {
+	errs := []error{}
 	if a.Image == "" {
-		return errImageEmpty
+		errs = append(errs, fieldError{"app.image", errImageEmpty})
 	}
-	return nil
+
+	a.secretVars = map[string]bool{}
+	keys := make([]string, 0, len(a.EnvVars))
+	for k := range a.EnvVars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value, isSecret, err := resolveSecretFile(a.EnvVars[k])
+		if err != nil {
+			errs = append(errs, fieldError{"app.environment-variables." + k, fmt.Errorf("config error: run.app.environment-variables.%s can not be resolved: %v", k, err)})
+			continue
+		}
+		if isSecret {
+			a.EnvVars[k] = value
+			a.secretVars[k] = true
+		}
+	}
+	if a.RuntimeMetrics != nil {
+		errs = append(errs, a.RuntimeMetrics.defaultAndValidate(a.Port)...)
+	}
+	if a.Profiling != nil {
+		errs = append(errs, a.Profiling.defaultAndValidate(a.Port)...)
+	}
+	return errs
 }
 
-func (t *TrafficDriver) defaultAndValidate() error {
+func (p *Profiling) defaultAndValidate(servicePort *uint) []error {
+	errs := []error{}
+	if p.Path == "" {
+		p.Path = defaultProfilingPath
+	}
+	p.Path = "/" + strings.Trim(p.Path, "/")
+	if p.Port == nil {
+		if servicePort == nil {
+			errs = append(errs, fieldError{"app.profiling", errProfilingPortEmpty})
+		}
+		p.Port = servicePort
+	}
+	if p.CPUDuration == "" {
+		p.CPUDuration = defaultCPUDuration
+	} else {
+		duration, err := validateDuration(cpuDurationField, p.CPUDuration)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			p.CPUDuration = duration
+		}
+	}
+	return errs
+}
+
+func (r *RuntimeMetrics) defaultAndValidate(servicePort *uint) []error {
+	errs := []error{}
+	r.Format = strings.TrimSpace(strings.ToLower(r.Format))
+	if r.Format != prometheusFormat && r.Format != expvarFormat {
+		errs = append(errs, fieldError{"app.runtime-metrics.format", errRuntimeFormatInvalid})
+	}
+	if r.Path == "" {
+		r.Path = defaultRuntimeMetricsPath[r.Format]
+	}
+	if !strings.HasPrefix(r.Path, "/") {
+		r.Path = "/" + r.Path
+	}
+	if r.Port == nil {
+		if servicePort == nil {
+			errs = append(errs, fieldError{"app.runtime-metrics", errRuntimePortEmpty})
+		}
+		r.Port = servicePort
+	}
+	if len(r.Metrics) == 0 {
+		r.Metrics = defaultRuntimeMetrics[r.Format]
+	}
+	return errs
+}
+
+func (t *TrafficDriver) defaultAndValidate() []error {
+	errs := []error{}
 	if t.Endpoint == "" {
 		t.Endpoint = "/"
 	}
@@ -176,26 +431,27 @@ func (t *TrafficDriver) defaultAndValidate() error {
 	if t.Delay == "" {
 		t.Delay = delay
 	} else {
-		duration, err := validateDuration(t.Delay)
+		duration, err := validateDuration(startupDelayField, t.Delay)
 		if err != nil {
-			return err
+			errs = append(errs, err)
+		} else {
+			t.Delay = duration
 		}
-
-		t.Delay = duration
 	}
-	return t.Traffic.defaultAndValidate()
+	return append(errs, t.Traffic.defaultAndValidate()...)
 }
 
-func (t *Traffic) defaultAndValidate() error {
+func (t *Traffic) defaultAndValidate() []error {
+	errs := []error{}
 	if t.Duration == "" {
 		t.Duration = duration
 	} else {
-		duration, err := validateDuration(t.Duration)
+		duration, err := validateDuration(trafficDurationField, t.Duration)
 		if err != nil {
-			return err
+			errs = append(errs, err)
+		} else {
+			t.Duration = duration
 		}
-
-		t.Duration = duration
 	}
 	if t.Rate == nil {
 		t.Rate = UintPointer(rate)
@@ -204,7 +460,7 @@ func (t *Traffic) defaultAndValidate() error {
 		t.Users = UintPointer(users)
 	}
 
-	return nil
+	return errs
 }
 
 func UintPointer(val int) *uint {
@@ -212,13 +468,13 @@ func UintPointer(val int) *uint {
 	return &a
 }
 
-func CreateConfig(file string) {
+func CreateConfig(file string) error {
 	config, err := defaultConfig()
 	if err != nil {
-		handle.InternalError(err)
+		return err
 	}
 
-	os.WriteFile(file, config, 0664)
+	return os.WriteFile(file, config, 0664)
 }
 
 // defaultConfig returns a boiler plate config file with
@@ -237,7 +493,8 @@ func defaultConfig() ([]byte, error) {
 					},
 				},
 				Data: Data{
-					Interval: intervalStr,
+					Interval:  intervalStr,
+					Collector: dockerCollector,
 				},
 				TrafficDriver: TrafficDriver{
 					Endpoint: "/your_http_endpoint",
@@ -256,28 +513,51 @@ func defaultConfig() ([]byte, error) {
 	return yaml.Marshal(config)
 }
 
-func (cfg *RunConfig) CreateJobs() Batch {
+func (cfg *RunConfig) CreateJobs() (Batch, error) {
 	log.Info().Msg("Creating Docker Compose workspaces for jobs...")
 	jobs := make([]Job, len(cfg.Runs))
 	workspace, err := CreateJobWorkspace(JobsDir)
 	if err != nil {
-		handle.InternalError(err)
+		return nil, err
+	}
+
+	err = writeWorkspaceIgnore(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	// the certificate of the local collector is mounted into the app containers
+	if cfg.Server == localServer {
+		_, err = collectorCertificate(workspace)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	for i, run := range cfg.Runs {
 		log.Debug().Msgf("\ncreating resources for job \"%s\"", run.Name)
-		job, compose := run.toJob(cfg.LicenseKey, cfg.CollectionEndpoint)
+		job, compose, err := run.toJob(cfg)
+		if err != nil {
+			return nil, err
+		}
 		jobs[i] = job
 		jobDir, err := compose.WriteFile(job.Name, workspace)
 		if err != nil {
-			handle.InternalError(err)
+			return nil, err
 		}
 
 		jobs[i].Directory = jobDir
+
+		if len(run.Matrix) > 0 {
+			err = writeMatrixFile(jobDir, run.Name, run.Matrix)
+			if err != nil {
+				return nil, err
+			}
+		}
 		log.Debug().Msg("job succesfully created!\n")
 	}
 
-	return jobs
+	return jobs, nil
 }
 
 const (
@@ -286,94 +566,243 @@ const (
 )
 
 // ToJob converts a run to a runnable job
-func (run *Run) toJob(licenseKey, endpoint string) (Job, DockerCompose) {
-	// Get Durations
+func (run *Run) toJob(cfg *RunConfig) (Job, DockerCompose, error) {
+	timing, err := run.timing()
+	if err != nil {
+		return Job{}, DockerCompose{}, ConfigError{err}
+	}
+
+	// Create Docker Compose Object
+	compose := DockerCompose{
+		Version:  composeVersion,
+		Services: map[string]Service{},
+	}
+
+	// secrets are passed to the app through an env_file instead of being written to the compose file
+	env, secretEnv := splitSecrets(run.appEnv(cfg), run.secretKeys())
+	compose.Services[appName] = Service{
+		Image:       run.App.Image,
+		Ports:       run.appPorts(),
+		Environment: env,
+	}
+	if len(secretEnv) > 0 {
+		app := compose.Services[appName]
+		app.EnvFile = []string{secretsFile}
+		compose.Services[appName] = app
+		compose.secrets = secretEnv
+	}
+
+	exporter := exporterProfiles[run.Exporter]
+	exporter.configure(cfg, &compose)
+
+	compose.Services[driverName] = Service{
+		Image:       run.TrafficDriver.Image,
+		Environment: run.driverEnv(appName, timing.delay),
+		DependsOn: []string{
+			appName,
+		},
+	}
+
+	return Job{
+		Name:                   run.Name,
+		Config:                 *run,
+		Settings:               cfg,
+		SummaryStatisticsData:  run.SummaryStatistic,
+		Collector:              run.Data.Collector,
+		RuntimeMetrics:         run.App.RuntimeMetrics,
+		Profiling:              run.App.Profiling,
+		ProfileDuration:        timing.profile,
+		Baseline:               run.Baseline,
+		Matrix:                 run.Matrix,
+		Exporter:               run.Exporter,
+		ReceiverPort:           exporter.receiverPort(cfg),
+		DataCollectionInterval: timing.interval,
+		ExpectedRunTime:        timing.traffic + timing.delay,
+		LoadDuration:           timing.traffic,
+		LoadDelay:              timing.delay,
+		Warmup:                 timing.warmup,
+		Cooldown:               timing.cooldown,
+		AutoWarmup:             timing.autoWarmup,
+	}, compose, nil
+}
+
+// jobTiming are the durations of a job
+type jobTiming struct {
+	interval   time.Duration
+	traffic    time.Duration
+	delay      time.Duration
+	profile    time.Duration
+	warmup     time.Duration
+	cooldown   time.Duration
+	autoWarmup bool
+}
+
+// timing parses the durations of a job, and checks that data can be collected accurately with them
+func (run *Run) timing() (jobTiming, error) {
 	collectionInterval, err := parseDuration(run.Data.Interval)
 	if err != nil {
-		handle.InternalError(err)
+		return jobTiming{}, err
 	}
 
 	trafficDuration, err := parseDuration(run.TrafficDriver.Traffic.Duration)
 	if err != nil {
-		handle.InternalError(err)
+		return jobTiming{}, err
 	}
 
 	trafficDelay, err := parseDuration(run.TrafficDriver.Delay)
 	if err != nil {
-		handle.InternalError(err)
+		return jobTiming{}, err
 	}
 
 	if trafficDuration.Seconds() < 20 && run.Data.SummaryStatistic {
-		handle.IncorrectUsage(errors.New("please use a `traffic-driver.traffic.duration` greater than 20 seconds for summary statistic jobs, otherwise data will likely be inaccurate"))
+		return jobTiming{}, fieldError{trafficDurationField, errors.New("please use a `traffic-driver.traffic.duration` greater than 20 seconds for summary statistic jobs, otherwise data will likely be inaccurate")}
 	}
 
 	if collectionInterval >= trafficDuration {
-		handle.IncorrectUsage(fmt.Errorf("data collection interval %s for job %s can not be greater than or equal to the total experiment duration %s", collectionInterval.String(), run.Name, trafficDuration.String()))
+		return jobTiming{}, fieldError{collectionIntervalField, fmt.Errorf("data collection interval %s for job %s can not be greater than or equal to the total experiment duration %s", collectionInterval.String(), run.Name, trafficDuration.String())}
 	}
 
-	if collectionInterval > 10*time.Second || collectionInterval < 500*time.Millisecond {
-		handle.IncorrectUsage(fmt.Errorf("data collected for job %s will not be accurate when collected at an interval of %s. Keep the collection interval between 500 milliseconds and 100 seconds", run.Name, collectionInterval.String()))
+	// the docker stats api only refreshes about once a second, reading the cgroup directly is much finer
+	minInterval := 500 * time.Millisecond
+	if run.Data.Collector == cgroupCollector {
+		minInterval = 10 * time.Millisecond
 	}
 
-	// Create Docker Compose Object
-	compose := DockerCompose{
-		Version:  composeVersion,
-		Services: map[string]Service{},
+	if collectionInterval > 10*time.Second || collectionInterval < minInterval {
+		return jobTiming{}, fieldError{collectionIntervalField, fmt.Errorf("data collected for job %s will not be accurate when collected at an interval of %s. Keep the collection interval between %s and 10 seconds", run.Name, collectionInterval.String(), minInterval.String())}
 	}
 
-	compose.Services[appName] = Service{
-		Image:       run.App.Image,
-		Ports:       []string{},
-		Environment: run.appEnv(licenseKey, endpoint),
+	warmup, cooldown := defaultWarmup, defaultCooldown
+	auto := run.Data.Warmup == autoWarmup
+	if auto {
+		warmup = 0
+	} else if run.Data.Warmup != "" {
+		warmup, err = parseDuration(run.Data.Warmup)
+		if err != nil {
+			return jobTiming{}, err
+		}
+	}
+	if run.Data.Cooldown != "" {
+		cooldown, err = parseDuration(run.Data.Cooldown)
+		if err != nil {
+			return jobTiming{}, err
+		}
+	}
+	if warmup+cooldown >= trafficDuration {
+		return jobTiming{}, fieldError{warmupField, fmt.Errorf("the warmup %s and cooldown %s of job %s leave no steady state in its traffic duration of %s", warmup, cooldown, run.Name, trafficDuration)}
 	}
 
-	compose.Services[driverName] = Service{
-		Image:       run.TrafficDriver.Image,
-		Environment: run.driverEnv(appName, int(trafficDelay.Seconds())),
-		DependsOn: []string{
-			appName,
+	// profile within the same steady state window that summary statistics are collected in, an
+	// automatic warmup is not known until the job is done so the default is assumed
+	var profileDuration time.Duration
+	if run.App.Profiling != nil {
+		profileDuration, err = parseDuration(run.App.Profiling.CPUDuration)
+		if err != nil {
+			return jobTiming{}, err
+		}
+
+		profileWarmup := warmup
+		if auto {
+			profileWarmup = defaultWarmup
+		}
+		window := trafficDuration - (profileWarmup + cooldown)
+		if profileDuration > window {
+			profileDuration = window
+		}
+		if profileDuration < time.Second {
+			return jobTiming{}, fieldError{trafficDurationField, fmt.Errorf("the traffic duration of job %s is too short to capture a cpu profile, use a duration greater than %s", run.Name, (profileWarmup + cooldown + time.Second).String())}
+		}
+	}
+
+	return jobTiming{
+		interval:   collectionInterval,
+		traffic:    trafficDuration,
+		delay:      trafficDelay,
+		profile:    profileDuration,
+		warmup:     warmup,
+		cooldown:   cooldown,
+		autoWarmup: auto,
+	}, nil
+}
+
+// addLocalCollector forwards connections to the collector service on to the local collector in
+// agent-p, and makes the app trust its certificate
+func addLocalCollector(compose *DockerCompose, port uint) {
+	compose.Services[collectorName] = Service{
+		Image:      collectorImage,
+		ExtraHosts: []string{hostGateway},
+		Command: []string{
+			"tcp-listen:443,fork,reuseaddr",
+			fmt.Sprintf("tcp-connect:host.docker.internal:%d", port),
 		},
 	}
 
-	return Job{
-		Name:                   run.Name,
-		SummaryStatisticsData:  run.SummaryStatistic,
-		DataCollectionInterval: collectionInterval,
-		ExpectedRunTime:        trafficDuration + trafficDelay,
-		LoadDuration:           trafficDuration,
-		LoadDelay:              trafficDelay,
-	}, compose
+	app := compose.Services[appName]
+	app.DependsOn = append(app.DependsOn, collectorName)
+	trustCertificate(&app, fmt.Sprintf("../%s/%s", collectorCertDir, collectorCertFile), collectorCertPath)
+	compose.Services[appName] = app
 }
 
+// toComposeEnvVar lists vars sorted by name, so the compose file of a job is the same each time
+// it is written and --resume can tell that the job has not changed
 func toComposeEnvVar(vars map[string]string) []string {
-	out := []string{}
-	for k, v := range vars {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
 		if k != "" {
-			out = append(out, fmt.Sprintf("%s=%s", k, v))
+			keys = append(keys, k)
 		}
 	}
+	sort.Strings(keys)
 
+	out := []string{}
+	for _, k := range keys {
+		out = append(out, fmt.Sprintf("%s=%s", k, vars[k]))
+	}
 	return out
 }
 
-func (run *Run) appEnv(licenseKey, endpoint string) []string {
-	vars := []string{
-		fmt.Sprintf("%s=%s", "NEW_RELIC_LICENSE_KEY", licenseKey),
-		fmt.Sprintf("%s=%s", "NEW_RELIC_APP_NAME", run.Name),
+// appPorts publishes the ports agent-p reads from to a random port on the host's loopback interface
+func (run *Run) appPorts() []string {
+	ports := []string{}
+	published := map[uint]bool{}
+	publish := func(port uint) {
+		if !published[port] {
+			published[port] = true
+			ports = append(ports, fmt.Sprintf("127.0.0.1::%d", port))
+		}
 	}
 
-	if endpoint != "" {
-		vars = append(vars, fmt.Sprintf("%s=%s", "NEW_RELIC_HOST", endpoint))
+	if run.App.RuntimeMetrics != nil {
+		publish(*run.App.RuntimeMetrics.Port)
 	}
+	if run.App.Profiling != nil {
+		publish(*run.App.Profiling.Port)
+	}
+	return ports
+}
 
+// secretKeys are the environment variables of the app that must not be written to its compose file
+func (run *Run) secretKeys() map[string]bool {
+	keys := map[string]bool{
+		licenseKeyVar: true,
+	}
+	for k := range run.App.secretVars {
+		keys[k] = true
+	}
+	return keys
+}
+
+func (run *Run) appEnv(cfg *RunConfig) []string {
+	vars := exporterProfiles[run.Exporter].env(cfg, run)
 	vars = append(vars, toComposeEnvVar(run.App.EnvVars)...)
 	return vars
 }
 
-func (run *Run) driverEnv(appName string, delay int) []string {
+func (run *Run) driverEnv(appName string, delay time.Duration) []string {
 	vars := []string{
 		fmt.Sprintf("%s=%s", "APP_NAME", appName),
-		fmt.Sprintf("%s=%d", "TRAFFIC_DRIVER_DELAY", delay),
+		// sleep in the traffic driver does not read exponents, like the 1e+06 %g writes
+		fmt.Sprintf("%s=%s", "TRAFFIC_DRIVER_DELAY", strconv.FormatFloat(delay.Seconds(), 'f', -1, 64)),
 		fmt.Sprintf("%s=%d", "SERVICE_PORT", *run.App.Port),
 		fmt.Sprintf("%s=%s", "SERVICE_ENDPOINT", run.TrafficDriver.Endpoint),
 		fmt.Sprintf("%s=%d", "CONCURRENT_REQUESTS", *run.TrafficDriver.Traffic.Users),
@@ -383,48 +812,76 @@ func (run *Run) driverEnv(appName string, delay int) []string {
 	return vars
 }
 
-// GetConfig reads, unmarshals, and vaildates a RunConfig
-func GetConfig(file string) *RunConfig {
-	cfgBytes, err := os.ReadFile(file)
+// GetConfig reads, unmarshals, and vaildates a RunConfig. Problems in the config are returned as
+// ConfigProblems.
+func GetConfig(file string) (*RunConfig, error) {
+	cfg, problems, err := readConfig(file)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, warning := range problems.warnings() {
+		log.Warn().Msgf("config warning: %s", warning)
+	}
+	if errs := problems.errors(); len(errs) > 0 {
+		return nil, errs
+	}
+	return cfg, nil
+}
+
+// prepareConfig rewrites the parsed yaml of a config into the jobs it describes before it is decoded
+func prepareConfig(root *yaml.Node) error {
+	err := interpolateEnv(root)
 	if err != nil {
-		handle.InternalError(err)
+		return err
 	}
 
-	cfg := RunConfig{}
-	err = yaml.Unmarshal(cfgBytes, &cfg)
+	err = applyInheritance(root)
 	if err != nil {
-		handle.InternalError(err)
+		return err
 	}
 
-	err = cfg.defaultAndValidate()
+	err = expandMatrices(root)
 	if err != nil {
-		handle.IncorrectUsage(err)
+		return err
 	}
-	return &cfg
+
+	return executeJobTemplates(root)
 }
 
-func validateDuration(duration string) (string, error) {
+// durationUnits lists the units a duration can be written in, for error messages
+const durationUnits = "a number and a unit, like 500ms, 30s, 1m30s or 1h"
+
+// validateDuration checks a duration written in the config field, and returns it cleaned up.
+// Durations use the go duration syntax, and integers are read as seconds like they were before
+// 0.2.0. Only a startup delay, warmup and cooldown can be zero.
+func validateDuration(field, duration string) (string, error) {
 	clean := strings.TrimSpace(strings.ToLower(duration))
-	if regexp.MustCompile(`^(\d+)([s|m])$`).MatchString(clean) {
-		return clean, nil
+	if isInteger(clean) {
+		clean += "s"
 	}
-	return "", fmt.Errorf("collection-interval must have both a number and duration unit: examples: 3m or 1s. Duration \"%s\" is invalid", clean)
+
+	parsed, err := time.ParseDuration(clean)
+	if err != nil {
+		return "", fieldError{field, fmt.Errorf("config error: %s must be %s, \"%s\" is not a duration", field, durationUnits, strings.TrimSpace(duration))}
+	}
+	if parsed < 0 {
+		return "", fieldError{field, fmt.Errorf("config error: %s can not be negative, got \"%s\"", field, strings.TrimSpace(duration))}
+	}
+	if parsed == 0 && !zeroDurations[field] {
+		return "", fieldError{field, fmt.Errorf("config error: %s must be greater than zero, got \"%s\"", field, strings.TrimSpace(duration))}
+	}
+	return clean, nil
 }
 
+// parseDuration parses a duration cleaned up by validateDuration, an empty duration is zero
 func parseDuration(duration string) (time.Duration, error) {
-	if strings.Contains(duration, "s") {
-		duration, err := strconv.Atoi(strings.Split(duration, "s")[0])
-		if err != nil {
-			return -1 * time.Second, err
-		}
-
-		return time.Duration(duration) * time.Second, nil
-	} else {
-		duration, err := strconv.Atoi(strings.Split(duration, "m")[0])
-		if err != nil {
-			return -1 * time.Second, err
-		}
-
-		return time.Duration(duration) * time.Minute, nil
+	clean := strings.TrimSpace(strings.ToLower(duration))
+	if clean == "" {
+		return 0, nil
+	}
+	if isInteger(clean) {
+		clean += "s"
 	}
+	return time.ParseDuration(clean)
 }
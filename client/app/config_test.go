@@ -1,8 +1,12 @@
 package app
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestParseIntervalDuration(t *testing.T) {
@@ -29,13 +33,45 @@ func TestParseIntervalDuration(t *testing.T) {
 			duration:       `1m`,
 			expectDuration: 1 * time.Minute,
 		},
+		{
+			duration:       `100ms`,
+			expectDuration: 100 * time.Millisecond,
+		},
+		{
+			duration:       `1h`,
+			expectDuration: time.Hour,
+		},
+		{
+			duration:       `1m30s`,
+			expectDuration: 90 * time.Second,
+		},
+		{
+			duration:       ` 500MS `,
+			expectDuration: 500 * time.Millisecond,
+		},
+		{
+			duration:       `20`,
+			expectDuration: 20 * time.Second,
+		},
 		{
 			invalid:  true,
 			duration: `-1s`,
 		},
+		{
+			invalid:  true,
+			duration: `0s`,
+		},
+		{
+			invalid:  true,
+			duration: `abc`,
+		},
+		{
+			invalid:  true,
+			duration: `1 minute`,
+		},
 	}
 	for _, test := range tests {
-		durationStr, err := validateDuration(test.duration)
+		durationStr, err := validateDuration(collectionIntervalField, test.duration)
 		if test.invalid {
 			if err == nil {
 				t.Logf("Expected %s to fail, but it did not", test.duration)
@@ -58,3 +94,68 @@ func TestParseIntervalDuration(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateDurationErrors(t *testing.T) {
+	_, err := validateDuration(startupDelayField, "soon")
+	if err == nil || !strings.Contains(err.Error(), startupDelayField) {
+		t.Errorf("expected an error naming %s, got %v", startupDelayField, err)
+	}
+
+	// only the startup delay, warmup and cooldown can be zero
+	_, err = validateDuration(startupDelayField, "0")
+	if err != nil {
+		t.Errorf("expected a zero startup delay to be valid, got %v", err)
+	}
+	_, err = validateDuration(trafficDurationField, "0")
+	if err == nil || !strings.Contains(err.Error(), trafficDurationField) {
+		t.Errorf("expected an error naming %s, got %v", trafficDurationField, err)
+	}
+}
+
+func TestDriverEnvDelay(t *testing.T) {
+	run := Run{TrafficDriver: TrafficDriver{Traffic: Traffic{Rate: UintPointer(rate), Users: UintPointer(users)}}, App: App{Port: UintPointer(8000)}}
+	tests := map[time.Duration]string{
+		1500 * time.Millisecond: "TRAFFIC_DRIVER_DELAY=1.5",
+		1000000 * time.Second:   "TRAFFIC_DRIVER_DELAY=1000000",
+	}
+	for delay, expected := range tests {
+		env := run.driverEnv(appName, delay)
+		if env[1] != expected {
+			t.Errorf("expected %s, got %s", expected, env[1])
+		}
+	}
+}
+
+func TestComposeIsStable(t *testing.T) {
+	cfg := RunConfig{
+		Server:     "production",
+		LicenseKey: "compose-license-key",
+		Runs: []Run{{
+			Name: "env",
+			App: App{Image: "app", Port: UintPointer(8000), EnvVars: map[string]string{
+				"A": "1", "B": "2", "C": "3", "D": "4", "E": "5", "F": "6",
+			}},
+		}},
+	}
+	errs := cfg.defaultAndValidate()
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	var first []byte
+	for i := 0; i < 10; i++ {
+		_, compose, err := cfg.Runs[0].toJob(&cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := yaml.Marshal(compose)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = content
+		} else if !bytes.Equal(first, content) {
+			t.Fatalf("expected the same compose file each time, got:\n%s\nand:\n%s", first, content)
+		}
+	}
+}
@@ -1,7 +1,184 @@
 package app
 
-// TODO
-func GraphComparitiveTimeseriesData() {}
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+)
 
-// TODO
-func GraphComparitiveSummaryStatisticsData() {}
+const (
+	chartWidth  = 760
+	chartHeight = 280
+	chartLeft   = 60
+	chartRight  = 20
+	chartTop    = 30
+	chartBottom = 40
+	chartTicks  = 5
+)
+
+// chartColors are assigned to the jobs of a chart in order
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// chartSeries is the values of one job in a chart
+type chartSeries struct {
+	Name   string
+	Points []chartPoint
+}
+
+type chartPoint struct {
+	X, Y float64
+}
+
+// timeseriesSeries plots a metric of a job against the seconds since its first sample
+func timeseriesSeries(name string, points []dataPoint, value func(dataPoint) float64) chartSeries {
+	series := chartSeries{Name: name, Points: make([]chartPoint, len(points))}
+	for i, point := range points {
+		series.Points[i] = chartPoint{
+			X: point.Timestamp.Sub(points[0].Timestamp).Seconds(),
+			Y: value(point),
+		}
+	}
+	return series
+}
+
+// graphComparativeTimeseries draws the series of each job as a line in one svg chart
+func graphComparativeTimeseries(title, unit string, series []chartSeries) template.HTML {
+	maxX, maxY := 0.0, 0.0
+	for _, s := range series {
+		for _, p := range s.Points {
+			maxX = math.Max(maxX, p.X)
+			maxY = math.Max(maxY, p.Y)
+		}
+	}
+	maxX, maxY = niceCeiling(maxX), niceCeiling(maxY)
+
+	svg := &strings.Builder{}
+	openChart(svg, title)
+	drawAxes(svg, maxY, unit)
+	for i := 0; i <= chartTicks; i++ {
+		x := chartLeft + float64(i)/chartTicks*plotWidth()
+		fmt.Fprintf(svg, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x, chartHeight-chartBottom+16, formatTick(maxX*float64(i)/chartTicks))
+	}
+	fmt.Fprintf(svg, `<text x="%.1f" y="%d" text-anchor="middle">seconds</text>`, chartLeft+plotWidth()/2, chartHeight-6)
+
+	for i, s := range series {
+		if len(s.Points) == 0 {
+			continue
+		}
+		coordinates := make([]string, len(s.Points))
+		for j, p := range s.Points {
+			coordinates[j] = fmt.Sprintf("%.1f,%.1f", chartLeft+p.X/maxX*plotWidth(), plotY(p.Y, maxY))
+		}
+		fmt.Fprintf(svg, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"><title>%s</title></polyline>`,
+			chartColor(i), strings.Join(coordinates, " "), html.EscapeString(s.Name))
+	}
+	drawLegend(svg, series)
+	svg.WriteString("</svg>")
+	return template.HTML(svg.String())
+}
+
+// graphComparativeSummaryStatistics draws the mean and p95 of a metric of each job as bars
+func graphComparativeSummaryStatistics(title, unit string, names []string, summaries []*metricSummary) template.HTML {
+	maxY := 0.0
+	for _, summary := range summaries {
+		if summary != nil {
+			maxY = math.Max(maxY, summary.P95)
+			maxY = math.Max(maxY, summary.Mean)
+		}
+	}
+	maxY = niceCeiling(maxY)
+
+	svg := &strings.Builder{}
+	openChart(svg, title)
+	drawAxes(svg, maxY, unit)
+
+	slot := plotWidth() / float64(len(names)+1)
+	bar := math.Min(slot/3, 40)
+	for i, name := range names {
+		center := chartLeft + slot*float64(i+1)
+		fmt.Fprintf(svg, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, center, chartHeight-chartBottom+16, html.EscapeString(name))
+		summary := summaries[i]
+		if summary == nil {
+			continue
+		}
+		for j, value := range []float64{summary.Mean, summary.P95} {
+			x := center - bar + float64(j)*bar
+			y := plotY(value, maxY)
+			fmt.Fprintf(svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" fill-opacity="%s"><title>%s %s %.2f</title></rect>`,
+				x, y, bar, float64(chartHeight-chartBottom)-y, chartColor(i), []string{"0.55", "1"}[j],
+				html.EscapeString(name), []string{"mean", "p95"}[j], value)
+		}
+	}
+	fmt.Fprintf(svg, `<text x="%d" y="%d">lighter bars are the mean, darker bars are the p95</text>`, chartLeft, chartHeight-6)
+	svg.WriteString("</svg>")
+	return template.HTML(svg.String())
+}
+
+func openChart(svg *strings.Builder, title string) {
+	fmt.Fprintf(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" font-family="sans-serif" font-size="11">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(svg, `<text x="%d" y="18" font-size="14" font-weight="bold">%s</text>`, chartLeft, html.EscapeString(title))
+}
+
+// drawAxes draws the y axis with its ticks and grid lines, and the x axis
+func drawAxes(svg *strings.Builder, maxY float64, unit string) {
+	for i := 0; i <= chartTicks; i++ {
+		value := maxY * float64(i) / chartTicks
+		y := plotY(value, maxY)
+		fmt.Fprintf(svg, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e5e5e5"/>`, chartLeft, y, chartWidth-chartRight, y)
+		fmt.Fprintf(svg, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, chartLeft-6, y+4, formatTick(value))
+	}
+	fmt.Fprintf(svg, `<text x="14" y="%.1f" text-anchor="middle" transform="rotate(-90 14 %.1f)">%s</text>`,
+		chartTop+plotHeight()/2, chartTop+plotHeight()/2, html.EscapeString(unit))
+	fmt.Fprintf(svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, chartLeft, chartHeight-chartBottom, chartWidth-chartRight, chartHeight-chartBottom)
+	fmt.Fprintf(svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, chartLeft, chartTop, chartLeft, chartHeight-chartBottom)
+}
+
+func drawLegend(svg *strings.Builder, series []chartSeries) {
+	x := float64(chartLeft + 200)
+	for i, s := range series {
+		fmt.Fprintf(svg, `<rect x="%.1f" y="9" width="10" height="10" fill="%s"/><text x="%.1f" y="18">%s</text>`,
+			x, chartColor(i), x+14, html.EscapeString(s.Name))
+		x += 24 + 6.5*float64(len(s.Name))
+	}
+}
+
+func plotWidth() float64 {
+	return chartWidth - chartLeft - chartRight
+}
+
+func plotHeight() float64 {
+	return chartHeight - chartTop - chartBottom
+}
+
+func plotY(value, maxY float64) float64 {
+	return chartTop + plotHeight() - value/maxY*plotHeight()
+}
+
+func chartColor(i int) string {
+	return chartColors[i%len(chartColors)]
+}
+
+// niceCeiling rounds the top of an axis up to 1, 2, 2.5 or 5 times a power of ten, so its ticks
+// are round numbers
+func niceCeiling(value float64) float64 {
+	if value <= 0 || math.IsNaN(value) {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, step := range []float64{1, 2, 2.5, 5, 10} {
+		if value <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+func formatTick(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+}
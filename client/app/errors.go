@@ -0,0 +1,95 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConfigError is a problem with what was asked of agent-p, rather than a failure while doing it
+type ConfigError struct {
+	Err error
+}
+
+func (e ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// IsConfigError is true when an error was caused by a config or the inputs of a command
+func IsConfigError(err error) bool {
+	var configErr ConfigError
+	var problems ConfigProblems
+	return errors.As(err, &configErr) || errors.As(err, &problems)
+}
+
+// ComposeError is a docker compose command that exited with an error
+type ComposeError struct {
+	Command string
+	Err     error
+}
+
+func (e ComposeError) Error() string {
+	return fmt.Sprintf("docker compose exited with an error: %v. To troubleshoot this, please run \"%s\"", e.Err, e.Command)
+}
+
+func (e ComposeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrInterrupted is returned by a job, or a batch, that was stopped by a signal before it finished
+var ErrInterrupted = errors.New("interrupted")
+
+// Job statuses
+const (
+	succeededStatus   = "succeeded"
+	failedStatus      = "failed"
+	interruptedStatus = "interrupted"
+	skippedStatus     = "skipped"
+	notRunStatus      = "not run"
+)
+
+// jobResult is the outcome of a single job in a batch
+type jobResult struct {
+	Name     string
+	Status   string
+	Err      error
+	Duration time.Duration
+}
+
+func newJobResult(name string, err error, duration time.Duration) jobResult {
+	status := succeededStatus
+	if errors.Is(err, ErrInterrupted) {
+		status = interruptedStatus
+	} else if err != nil {
+		status = failedStatus
+	}
+	return jobResult{Name: name, Status: status, Err: err, Duration: duration}
+}
+
+// BatchError lists the jobs of a batch that did not succeed, every other job in the batch succeeded
+type BatchError struct {
+	Jobs        int
+	Failed      []jobResult
+	Interrupted bool
+}
+
+func (e BatchError) Error() string {
+	failures := make([]string, len(e.Failed))
+	for i, result := range e.Failed {
+		failures[i] = fmt.Sprintf("%s %s: %v", result.Name, result.Status, result.Err)
+	}
+	if e.Interrupted {
+		return fmt.Sprintf("the batch was interrupted, %d of %d jobs did not complete\n%s", len(e.Failed), e.Jobs, strings.Join(failures, "\n"))
+	}
+	return fmt.Sprintf("%d of %d jobs failed\n%s", len(e.Failed), e.Jobs, strings.Join(failures, "\n"))
+}
+
+// Is lets errors.Is(err, ErrInterrupted) tell an interrupted batch apart from one that failed
+func (e BatchError) Is(target error) bool {
+	return e.Interrupted && target == ErrInterrupted
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	err := summarize([]jobResult{
+		newJobResult("a", nil, 0),
+		newJobResult("b", ComposeError{Command: "docker compose up", Err: errors.New("exit status 1")}, 0),
+		newJobResult("c", nil, 0),
+	})
+
+	var batchErr BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	if batchErr.Jobs != 3 || len(batchErr.Failed) != 1 || batchErr.Failed[0].Name != "b" {
+		t.Errorf("unexpected batch error %+v", batchErr)
+	}
+	if !strings.Contains(err.Error(), "1 of 3 jobs failed") || !strings.Contains(err.Error(), "docker compose up") {
+		t.Errorf("unexpected message %s", err)
+	}
+
+	if err := summarize([]jobResult{newJobResult("a", nil, 0)}); err != nil {
+		t.Errorf("expected no error when every job succeeds, got %v", err)
+	}
+	if errors.Is(err, ErrInterrupted) {
+		t.Error("a batch that failed should not be interrupted")
+	}
+}
+
+func TestSummarizeInterrupted(t *testing.T) {
+	err := summarize([]jobResult{
+		newJobResult("a", nil, 0),
+		newJobResult("b", ErrInterrupted, 0),
+		{Name: "c", Status: skippedStatus, Err: ErrInterrupted},
+	})
+
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected the batch to be interrupted, got %v", err)
+	}
+	var batchErr BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 2 {
+		t.Errorf("expected the interrupted and skipped jobs to be listed, got %v", err)
+	}
+}
+
+func TestIsConfigError(t *testing.T) {
+	tests := []struct {
+		err    error
+		expect bool
+	}{
+		{ConfigError{errors.New("bad")}, true},
+		{fmt.Errorf("wrapped: %w", ConfigError{errors.New("bad")}), true},
+		{ConfigProblems{{Message: "bad"}}, true},
+		{ComposeError{Command: "docker compose up", Err: errors.New("exit status 1")}, false},
+		{BatchError{Jobs: 1, Failed: []jobResult{{Name: "a", Err: errors.New("failed")}}}, false},
+	}
+
+	for _, test := range tests {
+		if IsConfigError(test.err) != test.expect {
+			t.Errorf("IsConfigError(%v) should be %v", test.err, test.expect)
+		}
+	}
+}
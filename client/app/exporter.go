@@ -0,0 +1,108 @@
+package app
+
+import (
+	"fmt"
+)
+
+// Exporters
+const (
+	newRelicExporter = "newrelic"
+	otlpExporter     = "otlp"
+
+	defaultOTLPReceiverPort = 4318
+)
+
+// hostGateway lets a container reach the receivers agent-p serves on the host
+const hostGateway = "host.docker.internal:host-gateway"
+
+// exporterProfile configures where the app of a job sends its telemetry
+type exporterProfile interface {
+	// env returns the environment variables that point the app at its telemetry endpoint
+	env(cfg *RunConfig, run *Run) []string
+	// configure adds the services and settings the exporter needs to the compose file of a job
+	configure(cfg *RunConfig, compose *DockerCompose)
+	// receiverPort is the port of the job local receiver of the exporter, or 0 when there is none
+	receiverPort(cfg *RunConfig) uint
+}
+
+var exporterProfiles = map[string]exporterProfile{
+	newRelicExporter: newRelicProfile{},
+	otlpExporter:     otlpProfile{},
+}
+
+// receiver records the telemetry the app of a job sends while it runs
+type receiver interface {
+	// begin clears anything recorded before a job starts
+	begin()
+	// end writes what was recorded while the job ran to its directory
+	end(dir JobDirectory) error
+	Close()
+}
+
+// startReceiver starts the job local receiver of an exporter
+func startReceiver(exporter string, port uint) (receiver, error) {
+	switch exporter {
+	case newRelicExporter:
+		return startLocalCollector(port, "./"+JobsDir+"/")
+	case otlpExporter:
+		return startOTLPReceiver(port)
+	}
+	return nil, fmt.Errorf("exporter %s has no receiver", exporter)
+}
+
+// newRelicProfile sends telemetry with a New Relic language agent
+type newRelicProfile struct{}
+
+func (newRelicProfile) env(cfg *RunConfig, run *Run) []string {
+	vars := []string{
+		fmt.Sprintf("%s=%s", licenseKeyVar, cfg.LicenseKey),
+		fmt.Sprintf("%s=%s", "NEW_RELIC_APP_NAME", run.Name),
+	}
+
+	vars = append(vars, cfg.endpoint.env()...)
+	return vars
+}
+
+func (newRelicProfile) configure(cfg *RunConfig, compose *DockerCompose) {
+	switch cfg.Server {
+	case localServer:
+		addLocalCollector(compose, *cfg.LocalCollectorPort)
+	case customServer:
+		if cfg.CustomServer.CABundle != "" {
+			app := compose.Services[appName]
+			trustCertificate(&app, cfg.CustomServer.CABundle, caBundlePath)
+			compose.Services[appName] = app
+		}
+	}
+}
+
+func (newRelicProfile) receiverPort(cfg *RunConfig) uint {
+	if cfg.Server == localServer {
+		return *cfg.LocalCollectorPort
+	}
+	return 0
+}
+
+// otlpProfile sends telemetry with an OpenTelemetry SDK to the otlp receiver in agent-p
+type otlpProfile struct{}
+
+func (otlpProfile) env(cfg *RunConfig, run *Run) []string {
+	return []string{
+		fmt.Sprintf("%s=http://host.docker.internal:%d", "OTEL_EXPORTER_OTLP_ENDPOINT", *cfg.OTLPReceiverPort),
+		fmt.Sprintf("%s=%s", "OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf"),
+		fmt.Sprintf("%s=%s", "OTEL_SERVICE_NAME", run.Name),
+		fmt.Sprintf("%s=%s", "OTEL_TRACES_EXPORTER", "otlp"),
+		fmt.Sprintf("%s=%s", "OTEL_METRICS_EXPORTER", "otlp"),
+		fmt.Sprintf("%s=%s", "OTEL_LOGS_EXPORTER", "otlp"),
+	}
+}
+
+func (otlpProfile) configure(cfg *RunConfig, compose *DockerCompose) {
+	app := compose.Services[appName]
+	app.ExtraHosts = append(app.ExtraHosts, hostGateway)
+	compose.Services[appName] = app
+}
+
+func (otlpProfile) receiverPort(cfg *RunConfig) uint {
+	return *cfg.OTLPReceiverPort
+}
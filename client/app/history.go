@@ -0,0 +1,97 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// History labels
+const (
+	DigestLabel  = "digest"
+	VersionLabel = "version"
+)
+
+// History writes a table of how the p95 cpu and memory of a job changed across its runs. Each
+// run is labelled by the digest of its app image, or by the version of the agent the app ran.
+func History(w io.Writer, job, label string) error {
+	if label != DigestLabel && label != VersionLabel {
+		return ConfigError{fmt.Errorf("unknown label %q, expected %s or %s", label, DigestLabel, VersionLabel)}
+	}
+
+	results, err := openExistingResults(ResultsDatabase())
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	runs, err := results.history(job)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		return ConfigError{fmt.Errorf("no runs of job %s have been recorded in %s", job, ResultsDatabase())}
+	}
+	return writeHistory(w, runs, label)
+}
+
+func writeHistory(w io.Writer, runs []runHistory, label string) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "RUN\tSTARTED\tSTATUS\t%s\tP95 CPU %%\tCHANGE\tP95 MEMORY MB\tCHANGE\n", strings.ToUpper(label))
+
+	var previousCPU, previousMemory *metricSummary
+	for _, run := range runs {
+		runLabel := run.AgentVersion
+		if label == DigestLabel {
+			runLabel = shortDigest(run.Digest)
+		} else if runLabel == "" {
+			runLabel = "-"
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			run.Run, run.Started.Local().Format(time.RFC822), run.Status, runLabel,
+			formatP95(run.CPU), formatChange(previousCPU, run.CPU),
+			formatP95(run.Memory), formatChange(previousMemory, run.Memory),
+		)
+
+		// changes are relative to the last run that collected data
+		if run.CPU != nil {
+			previousCPU = run.CPU
+		}
+		if run.Memory != nil {
+			previousMemory = run.Memory
+		}
+	}
+	return table.Flush()
+}
+
+// shortDigest trims the repository and most of the hash from a digest, like docker does
+func shortDigest(digest string) string {
+	if i := strings.LastIndex(digest, "@"); i >= 0 {
+		digest = digest[i+1:]
+	}
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+	if digest == "" {
+		return "-"
+	}
+	return digest
+}
+
+func formatP95(summary *metricSummary) string {
+	if summary == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", summary.P95)
+}
+
+func formatChange(previous, current *metricSummary) string {
+	if previous == nil || current == nil || previous.P95 == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (current.P95-previous.P95)/previous.P95*100)
+}
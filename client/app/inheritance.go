@@ -0,0 +1,132 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultsKey  = "defaults"
+	templatesKey = "templates"
+	extendsKey   = "extends"
+)
+
+// applyInheritance merges the defaults of a config and the templates each job extends into the
+// job. Defaults are merged first, then each template in the order it is extended, then the job
+// itself, so the job always has the last word. Mappings are merged key by key, lists and values
+// are replaced.
+func applyInheritance(root *yaml.Node) error {
+	config := documentRoot(root)
+	defaults := mappingValue(config, defaultsKey)
+	templates := mappingValue(config, templatesKey)
+	deleteMappingKey(config, defaultsKey)
+	deleteMappingKey(config, templatesKey)
+
+	if defaults != nil && defaults.Kind != yaml.MappingNode {
+		return fmt.Errorf("config error: defaults on line %d must be a job", defaults.Line)
+	}
+	if templates != nil && templates.Kind != yaml.MappingNode {
+		return fmt.Errorf("config error: templates on line %d must map names to jobs", templates.Line)
+	}
+
+	jobs := mappingValue(config, "jobs")
+	if jobs == nil || jobs.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	for i, job := range jobs.Content {
+		if job.Kind != yaml.MappingNode {
+			continue
+		}
+
+		extended, err := resolveTemplate(job, templates, nil)
+		if err != nil {
+			return err
+		}
+
+		merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: job.Line, Column: job.Column}
+		if defaults != nil {
+			merged = mergeNodes(merged, defaults)
+		}
+		jobs.Content[i] = mergeNodes(merged, extended)
+	}
+	return nil
+}
+
+// resolveTemplate returns a job merged on top of the templates it extends, and the templates they
+// extend in turn
+func resolveTemplate(job, templates *yaml.Node, chain []string) (*yaml.Node, error) {
+	extends := mappingValue(job, extendsKey)
+	if extends == nil {
+		return job, nil
+	}
+
+	names := []*yaml.Node{extends}
+	if extends.Kind == yaml.SequenceNode {
+		names = extends.Content
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: job.Line, Column: job.Column}
+	for _, name := range names {
+		if name.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("config error: extends on line %d must be a template name or a list of them", name.Line)
+		}
+		for _, seen := range chain {
+			if seen == name.Value {
+				return nil, fmt.Errorf("config error: template %s extends itself through %s", name.Value, strings.Join(chain, " -> "))
+			}
+		}
+
+		template := mappingValue(templates, name.Value)
+		if template == nil {
+			return nil, fmt.Errorf("config error: template %s on line %d is not defined in templates", name.Value, name.Line)
+		}
+		if template.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("config error: template %s on line %d must be a job", name.Value, template.Line)
+		}
+
+		resolved, err := resolveTemplate(template, templates, append(chain, name.Value))
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeNodes(merged, resolved)
+	}
+
+	own := cloneNode(job)
+	deleteMappingKey(own, extendsKey)
+	return mergeNodes(merged, own), nil
+}
+
+// mergeNodes deep merges override on top of base into a new node, neither is changed
+func mergeNodes(base, override *yaml.Node) *yaml.Node {
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return cloneNode(override)
+	}
+
+	merged := cloneNode(base)
+	merged.Line, merged.Column = override.Line, override.Column
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key := override.Content[i]
+		value := override.Content[i+1]
+		if existing := mappingValue(merged, key.Value); existing != nil {
+			setMappingValue(merged, key.Value, mergeNodes(existing, value))
+		} else {
+			merged.Content = append(merged.Content, cloneNode(key), cloneNode(value))
+		}
+	}
+	return merged
+}
+
+func deleteMappingKey(node *yaml.Node, key string) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestApplyInheritance(t *testing.T) {
+	config := `
+defaults:
+  data:
+    collection-interval: 2s
+  app:
+    image: my-app:latest
+    service-port: 8000
+    environment-variables:
+      A: default
+  traffic-driver:
+    traffic:
+      duration: 3m
+      requests-per-second: 100
+templates:
+  mysql:
+    traffic-driver:
+      service-endpoint: /mysql
+  slow:
+    extends: mysql
+    traffic-driver:
+      traffic:
+        requests-per-second: 10
+    app:
+      environment-variables:
+        B: slow
+jobs:
+  - name: plain
+  - name: slow-mysql
+    extends: slow
+    app:
+      environment-variables:
+        A: job
+`
+
+	root := yaml.Node{}
+	err := yaml.Unmarshal([]byte(config), &root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = prepareConfig(&root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := RunConfig{}
+	err = root.Decode(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := cfg.Runs[0]
+	if plain.App.Image != "my-app:latest" || plain.Data.Interval != "2s" || *plain.TrafficDriver.Traffic.Rate != 100 {
+		t.Errorf("defaults were not applied: %+v", plain)
+	}
+
+	job := cfg.Runs[1]
+	if job.TrafficDriver.Endpoint != "/mysql" {
+		t.Errorf("expected the endpoint of the mysql template, got %s", job.TrafficDriver.Endpoint)
+	}
+	if *job.TrafficDriver.Traffic.Rate != 10 || job.TrafficDriver.Traffic.Duration != "3m" {
+		t.Errorf("expected the traffic of the slow template merged on the defaults, got %+v", job.TrafficDriver.Traffic)
+	}
+	if job.App.EnvVars["A"] != "job" || job.App.EnvVars["B"] != "slow" {
+		t.Errorf("expected environment variables to be merged, got %v", job.App.EnvVars)
+	}
+	if job.App.Image != "my-app:latest" {
+		t.Errorf("expected the default image, got %s", job.App.Image)
+	}
+
+	if mappingValue(documentRoot(&root), defaultsKey) != nil || mappingValue(documentRoot(&root), templatesKey) != nil {
+		t.Error("defaults and templates should be removed once applied")
+	}
+}
+
+func TestApplyInheritanceErrors(t *testing.T) {
+	configs := []string{
+		"jobs:\n  - name: web\n    extends: missing\n",
+		"templates:\n  a:\n    extends: b\n  b:\n    extends: a\njobs:\n  - name: web\n    extends: a\n",
+		"defaults: [1]\njobs:\n  - name: web\n",
+	}
+
+	for _, config := range configs {
+		root := yaml.Node{}
+		err := yaml.Unmarshal([]byte(config), &root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if applyInheritance(&root) == nil {
+			t.Errorf("expected an error applying %q", config)
+		}
+	}
+}
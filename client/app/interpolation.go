@@ -0,0 +1,201 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envInterpolation matches $$, an escaped $, and ${VAR} or ${VAR:-default} references
+var envInterpolation = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// templateData is what the go templates in the fields of a job can read
+type templateData struct {
+	Job    templateJob
+	Matrix map[string]string
+}
+
+type templateJob struct {
+	Name  string
+	Index int
+}
+
+// interpolateEnv replaces the environment variable references in every value of a config. It works
+// on the parsed yaml, before it is decoded, so that numbers and booleans can be interpolated too.
+func interpolateEnv(root *yaml.Node) error {
+	return interpolateNode(root, nil)
+}
+
+// executeJobTemplates executes the go templates in the values of each job
+func executeJobTemplates(root *yaml.Node) error {
+	jobs := mappingValue(documentRoot(root), "jobs")
+	if jobs == nil || jobs.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	for i, job := range jobs.Content {
+		data := templateData{
+			Job:    templateJob{Index: i},
+			Matrix: matrixCoordinates(job),
+		}
+
+		// the name is executed first, so that the other fields can use it
+		name := mappingValue(job, "name")
+		if name != nil && name.Kind == yaml.ScalarNode {
+			err := executeTemplate(name, data)
+			if err != nil {
+				return err
+			}
+			data.Job.Name = name.Value
+		}
+
+		err := walkScalars(job, func(node *yaml.Node) error {
+			if node == name {
+				return nil
+			}
+			return executeTemplate(node, data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func interpolateNode(node *yaml.Node, path []string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			err := interpolateNode(child, path)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			err := interpolateNode(node.Content[i+1], append(path, node.Content[i].Value))
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		// a secret reference is resolved later, so that its value is kept out of compose files
+		if isSecretField(path) && envReference.MatchString(strings.TrimSpace(node.Value)) {
+			return nil
+		}
+
+		value, err := interpolate(node.Value)
+		if err != nil {
+			return fmt.Errorf("config error: %s on line %d: %v", strings.Join(path, "."), node.Line, err)
+		}
+		setScalar(node, value)
+	}
+	return nil
+}
+
+// isSecretField is true for the fields whose ${VAR} references are read as secrets
+func isSecretField(path []string) bool {
+	return len(path) == 1 && path[0] == "new-relic-license-key"
+}
+
+// interpolate replaces ${VAR} with the value of the environment variable VAR, and ${VAR:-default}
+// with default when VAR is unset or empty. $$ is replaced with a single $.
+func interpolate(value string) (string, error) {
+	var err error
+	interpolated := envInterpolation.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+
+		groups := envInterpolation.FindStringSubmatch(match)
+		env, ok := os.LookupEnv(groups[1])
+		if groups[2] != "" {
+			if env == "" {
+				return groups[3]
+			}
+			return env
+		}
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set, set it or give it a default with ${%s:-default}", groups[1], groups[1])
+		}
+		return env
+	})
+	return interpolated, err
+}
+
+func executeTemplate(node *yaml.Node, data templateData) error {
+	if !strings.Contains(node.Value, "{{") {
+		return nil
+	}
+
+	t, err := template.New("").Option("missingkey=error").Parse(node.Value)
+	if err != nil {
+		return fmt.Errorf("config error: template on line %d is invalid: %v", node.Line, err)
+	}
+
+	out := &strings.Builder{}
+	err = t.Execute(out, data)
+	if err != nil {
+		return fmt.Errorf("config error: template on line %d can not be executed: %v", node.Line, err)
+	}
+	setScalar(node, out.String())
+	return nil
+}
+
+// setScalar changes the value of a scalar and lets yaml resolve its type again, so that a value
+// like ${PORT:-8080} can be decoded into a number
+func setScalar(node *yaml.Node, value string) {
+	if value == node.Value {
+		return
+	}
+	node.Value = value
+	node.Tag = ""
+	node.Style = 0
+}
+
+func walkScalars(node *yaml.Node, fn func(*yaml.Node) error) error {
+	if node.Kind == yaml.ScalarNode {
+		return fn(node)
+	}
+	if node.Kind == yaml.MappingNode {
+		// keys are left alone
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			err := walkScalars(node.Content[i+1], fn)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, child := range node.Content {
+		err := walkScalars(child, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func documentRoot(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// mappingValue returns the value of a key in a yaml mapping, or nil when it is not set
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("AGENT_P_TEST_TAG", "v1.2.3")
+	t.Setenv("AGENT_P_TEST_EMPTY", "")
+
+	cases := []struct {
+		value  string
+		expect string
+		isErr  bool
+	}{
+		{"agent:${AGENT_P_TEST_TAG}", "agent:v1.2.3", false},
+		{"agent:${AGENT_P_TEST_TAG:-latest}", "agent:v1.2.3", false},
+		{"agent:${AGENT_P_TEST_EMPTY:-latest}", "agent:latest", false},
+		{"agent:${AGENT_P_TEST_UNSET:-latest}", "agent:latest", false},
+		{"${AGENT_P_TEST_EMPTY}", "", false},
+		{"$${AGENT_P_TEST_TAG}", "${AGENT_P_TEST_TAG}", false},
+		{"${AGENT_P_TEST_UNSET}", "", true},
+		{"no references", "no references", false},
+	}
+
+	for _, c := range cases {
+		value, err := interpolate(c.value)
+		if (err != nil) != c.isErr {
+			t.Errorf("interpolate(%s) error: %v", c.value, err)
+		}
+		if value != c.expect {
+			t.Errorf("interpolate(%s) = %s; expected %s", c.value, value, c.expect)
+		}
+	}
+}
+
+func TestPrepareConfig(t *testing.T) {
+	t.Setenv("AGENT_P_TEST_TAG", "v1.2.3")
+	t.Setenv("AGENT_P_TEST_PORT", "8081")
+	t.Setenv("AGENT_P_TEST_WORKERS", "4")
+
+	config := `
+version: 0.1.0
+new-relic-server: production
+new-relic-license-key: ${AGENT_P_TEST_LICENSE_KEY}
+jobs:
+  - name: job-{{ .Job.Index }}
+    app:
+      image: app:${AGENT_P_TEST_TAG}
+      service-port: ${AGENT_P_TEST_PORT:-8080}
+      environment-variables:
+        APP_NAME: "{{ .Job.Name }}"
+        WORKERS: ${AGENT_P_TEST_WORKERS}
+  - name: second
+    app:
+      image: app:latest
+      service-port: 8080
+`
+
+	root := yaml.Node{}
+	err := yaml.Unmarshal([]byte(config), &root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = prepareConfig(&root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := RunConfig{}
+	err = root.Decode(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.LicenseKey != "${AGENT_P_TEST_LICENSE_KEY}" {
+		t.Errorf("license key reference should be left for secret resolution, got %s", cfg.LicenseKey)
+	}
+
+	run := cfg.Runs[0]
+	if run.Name != "job-0" {
+		t.Errorf("expected name job-0, got %s", run.Name)
+	}
+	if run.App.Image != "app:v1.2.3" {
+		t.Errorf("expected image app:v1.2.3, got %s", run.App.Image)
+	}
+	if run.App.Port == nil || *run.App.Port != 8081 {
+		t.Errorf("expected service-port 8081, got %v", run.App.Port)
+	}
+	if run.App.EnvVars["APP_NAME"] != "job-0" {
+		t.Errorf("expected APP_NAME job-0, got %s", run.App.EnvVars["APP_NAME"])
+	}
+	if run.App.EnvVars["WORKERS"] != "4" {
+		t.Errorf("expected env var references to be interpolated, got %s", run.App.EnvVars["WORKERS"])
+	}
+}
+
+func TestPrepareConfigErrors(t *testing.T) {
+	configs := []string{
+		"jobs:\n  - name: ${AGENT_P_TEST_UNSET}\n",
+		"jobs:\n  - name: \"{{ .Job.Missing }}\"\n",
+		"jobs:\n  - name: \"{{ .Job.Name \"\n",
+	}
+
+	for _, config := range configs {
+		root := yaml.Node{}
+		err := yaml.Unmarshal([]byte(config), &root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prepareConfig(&root) == nil {
+			t.Errorf("expected an error interpolating %q", config)
+		}
+	}
+}
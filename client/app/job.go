@@ -1,16 +1,18 @@
 package app
 
 import (
-	"agent-p/handle"
-	"io"
+	"bytes"
 	"math/rand"
 
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -25,12 +27,28 @@ const (
 
 type Job struct {
 	SummaryStatisticsData  bool
+	Collector              string
+	RuntimeMetrics         *RuntimeMetrics
+	Profiling              *Profiling
+	ProfileDuration        time.Duration
+	Baseline               string
+	Matrix                 map[string]string
+	Exporter               string
+	ReceiverPort           uint
 	Name                   string
+	Config                 Run
+	Settings               *RunConfig // the top-level config the job was created from
 	Directory              JobDirectory
+	Output                 JobDirectory // the directory of the run of the job, where its results are written
 	ExpectedRunTime        time.Duration
 	LoadDuration           time.Duration
 	LoadDelay              time.Duration
 	DataCollectionInterval time.Duration
+	Warmup                 time.Duration // how long after traffic starts samples are not in the steady state
+	Cooldown               time.Duration // how long before traffic stops samples are not in the steady state
+	AutoWarmup             bool          // detect the warmup from the samples once the job is done
+
+	manifest *runManifest
 }
 
 const (
@@ -38,110 +56,427 @@ const (
 	DataDir = "data"
 )
 
+const (
+	// defaultWarmup is how long after traffic starts the app is assumed to have settled
+	defaultWarmup = 5 * time.Second
+	// defaultCooldown is how long before traffic stops collection ends, just to be defensive
+	defaultCooldown = 3 * time.Second
+)
+
 type Batch []Job
 
-func (b Batch) Run(clean bool) {
+// Run runs each job in the batch in order, and returns a BatchError listing every job that failed.
+// A failed job does not stop the rest of the batch, while a cancelled ctx skips the jobs after the
+// running one. When resume is set, jobs that succeeded with the same compose file are skipped.
+func (b Batch) Run(ctx context.Context, clean, resume bool) error {
 	log.Info().Msg("Running jobs...")
+
+	receivers := map[string]receiver{}
+	defer func() {
+		for _, r := range receivers {
+			r.Close()
+		}
+	}()
+
+	db, err := openResults(ResultsDatabase())
+	if err != nil {
+		log.Warn().Msgf("unable to open the results database, runs will not be recorded in it: %v", err)
+	} else {
+		defer db.Close()
+	}
+
+	runID := newRunID(time.Now())
+	results := make([]jobResult, len(b))
+	succeeded := Batch{}
+	for i := range b {
+		job := &b[i]
+		if ctx.Err() != nil {
+			results[i] = jobResult{Name: job.Name, Status: skippedStatus, Err: ErrInterrupted}
+			continue
+		}
+
+		if resume {
+			status, err := completedStatus(job.Directory)
+			if err != nil {
+				log.Warn().Msgf("unable to read the status of job %s, it will run again: %v", job.Name, err)
+			}
+			if status != nil {
+				job.Output = job.Directory.GetLatest()
+				log.Info().Msgf("job %s already has complete results from %s, skipping it", job.Name, status.Finished.Format(time.RFC3339))
+				results[i] = jobResult{Name: job.Name, Status: succeededStatus, Duration: status.Finished.Sub(status.Started)}
+				succeeded = append(succeeded, *job)
+				continue
+			}
+		}
+
+		start := time.Now()
+		job.Output, err = job.Directory.createRun(runID)
+		if err != nil {
+			results[i] = newJobResult(job.Name, err, time.Since(start))
+			log.Error().Msgf("job %s failed: unable to create a directory for its results: %v", job.Name, err)
+			continue
+		}
+
+		// a run without a complete manifest is still useful, so failing to write one does not fail the job
+		if manifestErr := job.startManifest(ctx, start); manifestErr != nil {
+			log.Warn().Msgf("unable to describe the run of job %s in its manifest: %v", job.Name, manifestErr)
+		}
+
+		err = job.runWithReceiver(ctx, receivers)
+		interrupted := errors.Is(err, ErrInterrupted)
+		// a failed or interrupted job may still have running containers, so it is torn down
+		// regardless, otherwise its traffic driver would keep running into the next job
+		if clean || err != nil {
+			cleanErr := job.Clean()
+			if err == nil {
+				err = cleanErr
+			}
+		}
+
+		results[i] = newJobResult(job.Name, err, time.Since(start))
+		if manifestErr := job.finishManifest(start.Add(results[i].Duration)); manifestErr != nil {
+			log.Warn().Msgf("unable to finish the manifest of job %s: %v", job.Name, manifestErr)
+		}
+		if statusErr := writeJobStatus(job, results[i], start); statusErr != nil {
+			log.Warn().Msgf("unable to record the status of job %s: %v", job.Name, statusErr)
+		}
+		if summaryErr := writeRunSummary(job); summaryErr != nil {
+			log.Warn().Msgf("unable to write the summary of job %s: %v", job.Name, summaryErr)
+		}
+		if resultsErr := db.recordResults(job, results[i]); resultsErr != nil {
+			log.Warn().Msgf("unable to record the results of job %s in the results database: %v", job.Name, resultsErr)
+		}
+		if interrupted {
+			log.Warn().Msgf("job %s was interrupted, the data collected so far was written to %s", job.Name, job.Output.GetDataFile())
+			continue
+		}
+		if err != nil {
+			log.Error().Msgf("job %s failed: %v", job.Name, err)
+			continue
+		}
+		succeeded = append(succeeded, *job)
+	}
+
+	succeeded.diffProfiles()
+	return summarize(results)
+}
+
+// runWithReceiver runs a job while the receiver of its exporter records the telemetry it sends
+func (j *Job) runWithReceiver(ctx context.Context, receivers map[string]receiver) error {
+	if j.ReceiverPort == 0 {
+		return j.run(ctx)
+	}
+
+	r := receivers[j.Exporter]
+	if r == nil {
+		var err error
+		r, err = startReceiver(j.Exporter, j.ReceiverPort)
+		if err != nil {
+			return fmt.Errorf("unable to start the %s receiver: %w", j.Exporter, err)
+		}
+		receivers[j.Exporter] = r
+	}
+
+	r.begin()
+	err := j.run(ctx)
+	endErr := r.end(j.Output)
+	if err != nil {
+		return err
+	}
+	return endErr
+}
+
+// summarize logs the outcome of each job in a batch, and returns a BatchError when any failed
+func summarize(results []jobResult) error {
+	failed := []jobResult{}
+	interrupted := false
+	for _, result := range results {
+		if result.Status == interruptedStatus || result.Status == skippedStatus {
+			interrupted = true
+		}
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	log.Info().Msgf("%d of %d jobs succeeded", len(results)-len(failed), len(results))
+	for _, result := range results {
+		if result.Status == skippedStatus {
+			log.Info().Msgf("  %s %s", result.Name, result.Status)
+			continue
+		}
+		log.Info().Msgf("  %s %s in %s", result.Name, result.Status, result.Duration.Round(time.Second))
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	return BatchError{Jobs: len(results), Failed: failed, Interrupted: interrupted}
+}
+
+// diffProfiles compares the profiles of each job against the profiles of its baseline
+func (b Batch) diffProfiles() {
+	jobs := map[string]Job{}
 	for _, job := range b {
-		job.run()
+		jobs[job.Name] = job
+	}
 
-		if clean {
-			job.Clean()
+	for _, job := range b {
+		baseline, ok := jobs[job.Baseline]
+		if !ok || job.Profiling == nil || baseline.Profiling == nil {
+			continue
 		}
+
+		log.Debug().Msgf("creating diff profiles for job %s against baseline %s...", job.Name, baseline.Name)
+		diffProfiles(job.Output, baseline.Output)
 	}
 }
 
-func (c *RunConfig) Clean() {
+// Clean tears down the docker resources of every job in a config, even when some fail to
+func (c *RunConfig) Clean() error {
+	var failed []string
 	for _, run := range c.Runs {
 		jobDir := ToLocalJobDirectory(run.Name)
-		doClean(jobDir)
+		err := doClean(jobDir)
+		if err != nil {
+			log.Error().Msg(err.Error())
+			failed = append(failed, run.Name)
+		}
 	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to clean up jobs: %s", strings.Join(failed, ", "))
+	}
+	return nil
 }
 
-func (j *Job) Clean() {
-	doClean(j.Directory)
+func (j *Job) Clean() error {
+	return doClean(j.Directory)
 }
 
-func doClean(j JobDirectory) {
+func doClean(j JobDirectory) error {
 	cmd := exec.Command("docker", "compose", "-f", j.GetCompose(), "down")
 	log.Debug().Msg(cmd.String())
 	err := cmd.Run()
 	if err != nil {
-		handle.DockerComposeError(cmd.String())
+		return ComposeError{Command: cmd.String(), Err: err}
 	}
 	log.Info().Msgf("cleaned up docker containers")
+	return nil
 }
 
-func (j *Job) run() {
+func (j *Job) run(ctx context.Context) error {
 	log.Debug().Msgf("running job %+v", j)
-	cmd := exec.Command("docker", "compose", "-f", j.Directory.GetCompose(), "up", "-d")
+	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", j.Directory.GetCompose(), "up", "-d")
 	log.Debug().Msgf("running job %s: %s", j.Name, cmd.String())
 	err := cmd.Run()
+	if ctx.Err() != nil {
+		return ErrInterrupted
+	}
 	if err != nil {
 		log.Debug().Msg(err.Error())
-		handle.DockerComposeError(cmd.String())
+		return ComposeError{Command: cmd.String(), Err: err}
 	}
 
-	appID, driverID := j.getContainerIDs()
+	appID, driverID, err := j.getContainerIDs(ctx)
+	if ctx.Err() != nil {
+		return ErrInterrupted
+	}
+	if err != nil {
+		return err
+	}
 	log.Debug().Msgf("app: %s\ndriver: %s", appID, driverID)
-	j.Monitor(appID, driverID)
+
+	err = j.recordImages(ctx, appID, driverID)
+	if err != nil {
+		log.Warn().Msgf("unable to record the images of job %s in its manifest: %v", j.Name, err)
+	}
+	err = j.Monitor(ctx, appID, driverID)
+	if j.AutoWarmup && (err == nil || errors.Is(err, ErrInterrupted)) {
+		tagErr := j.tagWarmup()
+		if tagErr != nil {
+			log.Warn().Msgf("unable to detect the warmup of job %s: %v", j.Name, tagErr)
+		}
+	}
+
+	// the driver is not removed until the job is cleaned up, so its logs are read even when
+	// monitoring was interrupted
+	logErr := j.saveDriverLogs(context.Background(), driverID)
+	if logErr != nil {
+		log.Warn().Msgf("unable to save the traffic driver logs of job %s: %v", j.Name, logErr)
+	}
+	return err
 }
 
-func (j *Job) getContainerIDs() (appID, driverID string) {
-	cmd := exec.Command("docker", "compose", "-f", j.Directory.GetCompose(), "ps", "--format", "json")
+// profileDelay is how long after monitoring starts the app is profiled, an automatic warmup is
+// not known until the job is done so the default is assumed
+func (j *Job) profileDelay() time.Duration {
+	if j.AutoWarmup {
+		return j.LoadDelay + defaultWarmup
+	}
+	return j.LoadDelay + j.Warmup
+}
+
+// composeContainer is a container listed by docker compose ps
+type composeContainer struct {
+	ID      string
+	Service string
+	State   string
+}
+
+func (j *Job) getContainerIDs(ctx context.Context) (appID, driverID string, err error) {
+	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", j.Directory.GetCompose(), "ps", "--format", "json")
 	log.Debug().Msgf("getting container ID's for job %s: %s", j.Name, cmd.String())
 	out, err := cmd.Output()
 	if err != nil {
-		handle.InternalError(err)
+		return "", "", ComposeError{Command: cmd.String(), Err: err}
+	}
+
+	containers, err := parseComposeContainers(out)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, container := range containers {
+		switch container.Service {
+		case appName:
+			appID = container.ID
+		case driverName:
+			driverID = container.ID
+		}
 	}
-	containers := []types.Container{}
-	json.Unmarshal(out, &containers)
 
-	if len(containers) != 2 {
-		handle.InternalError(fmt.Errorf("expecting only an app and driver container for job \"%s\", got: %v", j.Name, containers))
+	if appID == "" || driverID == "" {
+		return "", "", fmt.Errorf("expecting an app and driver container for job \"%s\", got: %v", j.Name, containers)
 	}
 
-	return containers[0].ID, containers[1].ID
+	return appID, driverID, nil
 }
 
-func (j *Job) Monitor(appID, driverID string) {
+// parseComposeContainers reads the output of docker compose ps, which is either a json array
+// or a json object per line depending on the version of compose
+func parseComposeContainers(out []byte) ([]composeContainer, error) {
+	containers := []composeContainer{}
+	trimmed := bytes.TrimSpace(out)
+	if len(trimmed) == 0 {
+		return containers, nil
+	}
+
+	if trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &containers)
+		return containers, err
+	}
+
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		container := composeContainer{}
+		err := json.Unmarshal(line, &container)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+// Monitor records the stats of a job's app until its traffic driver finishes. When ctx is
+// cancelled, the data collected so far is still written and ErrInterrupted is returned.
+func (j *Job) Monitor(ctx context.Context, appID, driverID string) error {
 	log.Debug().Msgf("monitoring and gathering data for job \"%s\"...", j.Name)
 	cli, err := client.NewClientWithOpts()
 	if err != nil {
-		handle.InternalError(err)
+		return err
 	}
 
-	fileName := j.Directory.GetDataFile()
+	// the app is stopped even when monitoring fails, so it does not skew the next job
+	defer func() {
+		timeout := time.Millisecond * 300
+		cli.ContainerStop(context.Background(), appID, &timeout)
+	}()
+	defer live.finish(j.Name, appName)
+
+	fileName := j.Output.GetDataFile()
 	dataFile, err := os.Create(fileName)
 	if err != nil {
-		handle.InternalError(err)
+		return err
 	}
 	defer dataFile.Close()
 
+	var stats statsSource
+	if j.Collector == cgroupCollector {
+		stats, err = sampleCgroup(cli, appID)
+		if err != nil {
+			return err
+		}
+	} else {
+		stats = streamStats(cli, appID)
+	}
+	defer stats.Close()
+
 	data := bufio.NewWriter(dataFile)
+	rec := &recorder{
+		job:   j,
+		start: time.Now(),
+		data:  data,
+		stats: stats,
+	}
+
+	if j.RuntimeMetrics != nil {
+		rec.runtime, err = newRuntimeScraper(cli, appID, j.RuntimeMetrics, scrapeTimeout(j.DataCollectionInterval))
+		if err != nil {
+			return err
+		}
+	}
+
 	writeTitle(data, j)
-	data.WriteString("Timestamp, CPU utilization %, Memory Usage Mb, Disk Write Kb, Outbound Network Traffic Kb\n")
+	rec.writeHeader()
 
-	trafficDriverFinished := make(chan bool)
-	quitChan := make(chan bool)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	profiling := make(chan struct{})
+	if j.Profiling != nil {
+		address, err := publishedAddress(cli, appID, *j.Profiling.Port)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer close(profiling)
+			newProfiler(address, j.Profiling, j.ProfileDuration).capture(ctx, j.Output, j.profileDelay())
+		}()
+	} else {
+		close(profiling)
+	}
+
+	trafficDriverFinished := make(chan bool, 1)
+	quitChan := make(chan bool, 1)
 	go watchContainer(cli, driverID, j.ExpectedRunTime+20*time.Second, trafficDriverFinished, quitChan)
 
 	if j.SummaryStatisticsData {
-		j.collectSummaryStatisticsData(data, cli, appID, trafficDriverFinished, quitChan)
+		err = j.collectSummaryStatisticsData(ctx, rec, trafficDriverFinished, quitChan)
 	} else {
-		j.collectTimeseriesData(data, cli, appID, trafficDriverFinished, quitChan)
+		err = j.collectTimeseriesData(ctx, rec, trafficDriverFinished, quitChan)
+	}
+	if err != nil {
+		cancel()
 	}
+	<-profiling
 
+	// whatever was collected before a failure is still written
 	log.Debug().Msgf("writing captued data to file: %s...", dataFile.Name())
-	err = data.Flush()
+	flushErr := data.Flush()
 	if err != nil {
-		handle.InternalError(err)
+		return err
+	}
+	if flushErr != nil {
+		return flushErr
 	}
 
 	log.Debug().Msgf("done monitoring job %s", j.Name)
-
-	timeout := time.Millisecond * 300
-	cli.ContainerStop(context.Background(), appID, &timeout)
+	return nil
 }
 
 func watchContainer(client *client.Client, containerID string, timeout time.Duration, finishedWatching chan bool, quitChan chan bool) {
@@ -160,84 +495,138 @@ func watchContainer(client *client.Client, containerID string, timeout time.Dura
 
 type statSnapshot struct {
 	Tx, CPU, System float64
+	Read            time.Time
 }
 
-func (j *Job) collectTimeseriesData(data *bufio.Writer, cli *client.Client, appID string, trafficDriverFinished chan bool, quit chan bool) {
-	previous := statSnapshot{}
+// recorder writes the samples of a job's app container to its dataset
+type recorder struct {
+	job      *Job
+	start    time.Time
+	data     *bufio.Writer
+	stats    statsSource
+	runtime  *runtimeScraper
+	previous statSnapshot
+}
+
+func (r *recorder) writeHeader() {
+	r.data.WriteString("Timestamp, Sample Interval ms, CPU utilization %, Memory Usage Mb, Disk Write Kb, Outbound Network Traffic Kb, ")
+	r.data.WriteString(phaseColumn)
+	if r.runtime != nil {
+		r.data.WriteString(", ")
+		r.data.WriteString(r.runtime.Header())
+	}
+	r.data.WriteString("\n")
+}
+
+// record writes a sample to the dataset, along with the runtime metrics of the app at that
+// moment. Samples that are not newer than the last one written are ignored.
+func (r *recorder) record(sample statSample) {
+	if !sample.Read.After(r.previous.Read) {
+		log.Debug().Msg("no new stats sample since the last one recorded, skipping...")
+		return
+	}
+
+	point, snapshot := toDataPoint(&sample, &r.previous)
+	point.Phase = r.job.samplePhase(sample.Read.Sub(r.start))
+	r.previous = snapshot
+	writeData(r.data, point)
+
+	var runtimeValues []*float64
+	if r.runtime != nil {
+		runtimeValues = r.runtime.Scrape()
+		writeRuntimeData(r.data, runtimeValues)
+	}
+	r.data.WriteString("\n")
+
+	live.update(r.job.Name, appName, point, r.runtime, runtimeValues)
+}
+
+// scrapeTimeout keeps scraping runtime metrics from delaying the next sample
+func scrapeTimeout(interval time.Duration) time.Duration {
+	timeout := interval / 2
+	if timeout < 50*time.Millisecond {
+		timeout = 50 * time.Millisecond
+	} else if timeout > 2*time.Second {
+		timeout = 2 * time.Second
+	}
+	return timeout
+}
+
+func (j *Job) collectTimeseriesData(ctx context.Context, rec *recorder, trafficDriverFinished chan bool, quit chan bool) error {
 	ticker := time.NewTicker(j.DataCollectionInterval)
+	defer ticker.Stop()
+	timeout := time.After(j.LoadDuration)
 	for {
 		select {
 		case <-ticker.C:
-			stats := getStats(cli, appID)
-			previous = writeData(data, &stats, &previous)
+			sample, ok := rec.stats.Latest()
+			if !ok {
+				if err := rec.stats.Err(); err != nil {
+					quit <- true
+					return err
+				}
+				continue
+			}
+			rec.record(sample)
 		case <-trafficDriverFinished:
 			log.Debug().Msg("recieved message that traffic driver has stopped")
-			return
-		case <-time.After(j.LoadDuration):
+			return nil
+		case <-timeout:
 			log.Debug().Msg("timeout reached, sending quit signal to watcher...")
 			quit <- true
-			return
+			return nil
+		case <-ctx.Done():
+			log.Debug().Msg("interrupted, sending quit signal to watcher...")
+			quit <- true
+			return ErrInterrupted
 		}
 	}
 }
 
 // data is random and only collected during periods of application load
-func (j *Job) collectSummaryStatisticsData(data *bufio.Writer, cli *client.Client, appID string, trafficDriverFinished chan bool, quit chan bool) {
-	previous := statSnapshot{}
-
-	// wait 5 seconds to avoid utilization spikes due to surge of traffic
-	collectionDelay := 5 * time.Second
-	log.Debug().Msgf("waiting %s to avoid usage spikes caused by a surge in traffic...", collectionDelay.String())
-	time.Sleep(j.LoadDelay + collectionDelay)
+func (j *Job) collectSummaryStatisticsData(ctx context.Context, rec *recorder, trafficDriverFinished chan bool, quit chan bool) error {
+	// wait to avoid utilization spikes due to surge of traffic
+	log.Debug().Msgf("waiting %s to avoid usage spikes caused by a surge in traffic...", j.Warmup.String())
+	select {
+	case <-time.After(j.LoadDelay + j.Warmup):
+	case <-ctx.Done():
+		quit <- true
+		return ErrInterrupted
+	}
 
 	log.Debug().Msgf("collecting summary statistics data randomly within a %s interval...", j.DataCollectionInterval.String())
-	// stop collecting 2 second before traffic stops being sent just to be defensive
-	timeoutPeriod := j.LoadDuration - (collectionDelay + 3*time.Second)
+	// stop collecting before traffic stops being sent just to be defensive
+	timeoutPeriod := j.LoadDuration - (j.Warmup + j.Cooldown)
 	log.Debug().Msgf("this collection process will time out in %s...", timeoutPeriod.String())
+	timeout := time.After(timeoutPeriod)
 
-	statsChan := make(chan types.StatsJSON, 1)
-	go getStatsRandomlyWithinInterval(j.DataCollectionInterval, cli, statsChan, appID)
+	samples := make(chan statSample, 1)
+	go getStatsRandomlyWithinInterval(j.DataCollectionInterval, rec.stats, samples)
 	for {
 		select {
 		case <-trafficDriverFinished:
 			log.Debug().Msg("recieved message that traffic driver has stopped")
-			return
-		case <-time.After(timeoutPeriod):
+			return nil
+		case <-timeout:
 			log.Debug().Msg("timeout reached, sending quit signal to watcher...")
 			quit <- true
-			return
-		case stats := <-statsChan:
-			previous = writeData(data, &stats, &previous)
-			go getStatsRandomlyWithinInterval(j.DataCollectionInterval, cli, statsChan, appID)
+			return nil
+		case <-ctx.Done():
+			log.Debug().Msg("interrupted, sending quit signal to watcher...")
+			quit <- true
+			return ErrInterrupted
+		case sample := <-samples:
+			if err := rec.stats.Err(); err != nil {
+				quit <- true
+				return err
+			}
+			rec.record(sample)
+			go getStatsRandomlyWithinInterval(j.DataCollectionInterval, rec.stats, samples)
 		}
 	}
 }
 
-func getStats(cli *client.Client, containerName string) types.StatsJSON {
-	statsReader, err := cli.ContainerStatsOneShot(context.TODO(), containerName)
-	if err != nil {
-		handle.InternalError(err)
-	}
-
-	defer statsReader.Body.Close()
-
-	stats := types.StatsJSON{}
-	buf, err := io.ReadAll(statsReader.Body)
-	if err != nil {
-		handle.InternalError(err)
-	}
-	err = json.Unmarshal(buf, &stats)
-	if err != nil {
-		handle.InternalError(err)
-	}
-
-	return stats
-}
-
-func getStatsRandomlyWithinInterval(interval time.Duration, cli *client.Client, statsChan chan types.StatsJSON, appID string) {
-	stats := getStats(cli, appID)
-	statsChan <- stats
-
+func getStatsRandomlyWithinInterval(interval time.Duration, stats statsSource, samples chan statSample) {
 	var sleepMillis int
 	if interval == time.Second {
 		// Try to prevent more than two or three checks per second to avoid straining the system
@@ -248,6 +637,12 @@ func getStatsRandomlyWithinInterval(interval time.Duration, cli *client.Client,
 
 	sleepTime := time.Duration(sleepMillis) * time.Millisecond
 	time.Sleep(sleepTime)
+
+	sample, ok := stats.Latest()
+	if !ok {
+		log.Debug().Msg("no stats sample has been read yet")
+	}
+	samples <- sample
 }
 
 func writeTitle(data *bufio.Writer, j *Job) {
@@ -261,7 +656,22 @@ func writeTitle(data *bufio.Writer, j *Job) {
 	data.WriteString("\n")
 }
 
-func writeData(data *bufio.Writer, stats *types.StatsJSON, previous *statSnapshot) statSnapshot {
+// dataPoint is a single row of a job's dataset
+type dataPoint struct {
+	Timestamp      time.Time
+	IntervalMs     float64
+	CPUPercent     float64
+	MemoryMb       float64
+	DiskWriteKb    float64
+	NetworkWriteKb float64
+	// Phase is whether the sample was read during the warmup, steady state or cooldown of the app
+	Phase string
+}
+
+// toDataPoint converts a sample into a row of the dataset, using the snapshot of the previous
+// sample to calculate the values that change over time
+func toDataPoint(sample *statSample, previous *statSnapshot) (dataPoint, statSnapshot) {
+	stats := &sample.Stats
 	cpuPercent := calculateCPUPercentUnix(previous.CPU, previous.System, stats)
 	previousCPU := float64(stats.CPUStats.CPUUsage.TotalUsage)
 	previousSystem := float64(stats.CPUStats.SystemUsage)
@@ -269,16 +679,44 @@ func writeData(data *bufio.Writer, stats *types.StatsJSON, previous *statSnapsho
 	txDiff := tx - previous.Tx
 	previousTx := tx
 
-	data.WriteString(time.Now().String())
+	// the interval is measured between the read times of the samples, the first sample has none
+	sampleInterval := 0.0
+	if !previous.Read.IsZero() {
+		sampleInterval = float64(sample.Read.Sub(previous.Read).Microseconds()) / 1000
+	}
+
+	point := dataPoint{
+		Timestamp:      sample.Read,
+		IntervalMs:     sampleInterval,
+		CPUPercent:     cpuPercent,
+		MemoryMb:       (float64(stats.MemoryStats.Usage) / 1024) / 1024,
+		DiskWriteKb:    float64(stats.StorageStats.WriteSizeBytes) / 1024,
+		NetworkWriteKb: txDiff / 1024,
+	}
+
+	return point, statSnapshot{
+		previousTx, previousCPU, previousSystem, sample.Read,
+	}
+}
+
+func writeData(data *bufio.Writer, point dataPoint) {
+	data.WriteString(point.Timestamp.Format(time.RFC3339Nano))
 	data.WriteByte(',')
-	data.WriteString(fmt.Sprintf("%.3f,", cpuPercent))
-	data.WriteString(fmt.Sprintf("%.3f,", (float64(stats.MemoryStats.Usage)/1024)/1024))
-	data.WriteString(fmt.Sprintf("%.3f,", float64(stats.StorageStats.WriteSizeBytes)/1024))
-	data.WriteString(fmt.Sprintf("%.3f", txDiff/1024))
-	data.WriteString("\n")
+	data.WriteString(fmt.Sprintf("%.3f,", point.IntervalMs))
+	data.WriteString(fmt.Sprintf("%.3f,", point.CPUPercent))
+	data.WriteString(fmt.Sprintf("%.3f,", point.MemoryMb))
+	data.WriteString(fmt.Sprintf("%.3f,", point.DiskWriteKb))
+	data.WriteString(fmt.Sprintf("%.3f,", point.NetworkWriteKb))
+	data.WriteString(point.Phase)
+}
 
-	return statSnapshot{
-		previousTx, previousCPU, previousSystem,
+// writeRuntimeData appends runtime metrics to a row, leaving the cells of missing metrics empty
+func writeRuntimeData(data *bufio.Writer, values []*float64) {
+	for _, value := range values {
+		data.WriteByte(',')
+		if value != nil {
+			data.WriteString(strconv.FormatFloat(*value, 'f', -1, 64))
+		}
 	}
 }
 
@@ -1,15 +1,26 @@
 package app
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type JobDirectory string
 
+const (
+	runsDir   = "runs"
+	latestRun = "latest"
+)
+
 func CreateJobWorkspace(workspaceName string) (string, error) {
 	return mkdirIfNotExists("./", workspaceName)
 }
@@ -19,6 +30,44 @@ func ToLocalJobDirectory(jobName string) JobDirectory {
 	return JobDirectory("./" + JobsDir + "/" + strings.ReplaceAll(jobName, " ", "-") + "/")
 }
 
+// writeWorkspaceIgnore keeps the secrets agent-p writes to a workspace out of version control. The
+// entries a workspace's .gitignore is missing are appended, so entries added by hand are kept.
+func writeWorkspaceIgnore(workspace string) error {
+	file := workspace + ".gitignore"
+	content, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, line := range strings.Split(string(content), "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+
+	missing := &strings.Builder{}
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		missing.WriteString("\n")
+	}
+	added := false
+	for _, entry := range []string{secretsFile, collectorCertDir + "/" + collectorKeyFile} {
+		if !existing[entry] {
+			missing.WriteString(entry + "\n")
+			added = true
+		}
+	}
+	if !added {
+		return nil
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(missing.String())
+	return err
+}
+
 func CreateJobDirectory(path, name string) (JobDirectory, error) {
 	filename, err := mkdirIfNotExists(path, strings.ReplaceAll(name, " ", "-"))
 	return JobDirectory(filename), err
@@ -28,10 +77,116 @@ func (jd JobDirectory) GetCompose() string {
 	return fmt.Sprintf("%sdocker-compose.yaml", jd)
 }
 
+func (jd JobDirectory) GetSecretsFile() string {
+	return fmt.Sprintf("%s%s", jd, secretsFile)
+}
+
+func (jd JobDirectory) GetLegacySecretsFile() string {
+	return fmt.Sprintf("%s%s", jd, legacySecretsFile)
+}
+
 func (jd JobDirectory) GetDataFile() string {
 	return fmt.Sprintf("%sdata.csv", jd)
 }
 
+func (jd JobDirectory) GetManifestFile() string {
+	return fmt.Sprintf("%smanifest.json", jd)
+}
+
+func (jd JobDirectory) GetDriverLogFile() string {
+	return fmt.Sprintf("%sdriver.log", jd)
+}
+
+func (jd JobDirectory) GetStatusFile() string {
+	return fmt.Sprintf("%sstatus.json", jd)
+}
+
+func (jd JobDirectory) GetSummaryFile() string {
+	return fmt.Sprintf("%ssummary.json", jd)
+}
+
+func (jd JobDirectory) GetMatrixFile() string {
+	return fmt.Sprintf("%smatrix.json", jd)
+}
+
+func (jd JobDirectory) GetCollectorFile() string {
+	return fmt.Sprintf("%scollector.csv", jd)
+}
+
+func (jd JobDirectory) GetOTLPFile() string {
+	return fmt.Sprintf("%sotlp.csv", jd)
+}
+
+func (jd JobDirectory) GetProfile(name string) string {
+	return fmt.Sprintf("%s%s.pprof", jd, name)
+}
+
+func (jd JobDirectory) GetDiffProfile(name string) string {
+	return fmt.Sprintf("%s%s.diff.pprof", jd, name)
+}
+
+// GetRunsDir is where the results of every run of a job are kept
+func (jd JobDirectory) GetRunsDir() string {
+	return fmt.Sprintf("%s%s/", jd, runsDir)
+}
+
+// GetRun is the directory holding the results of a single run of a job
+func (jd JobDirectory) GetRun(id string) JobDirectory {
+	return JobDirectory(fmt.Sprintf("%s%s/", jd.GetRunsDir(), id))
+}
+
+// GetLatest points to the directory of the most recent run of a job
+func (jd JobDirectory) GetLatest() JobDirectory {
+	return JobDirectory(fmt.Sprintf("%s%s/", jd, latestRun))
+}
+
+// runID is the name of a run directory
+func (jd JobDirectory) runID() string {
+	return filepath.Base(strings.TrimSuffix(string(jd), "/"))
+}
+
+// newRunID names a run after the time it started, so runs sort in the order they ran
+func newRunID(start time.Time) string {
+	return start.UTC().Format("20060102T150405Z")
+}
+
+// createRun creates the directory of a new run of a job, and points latest at it. A run that
+// would reuse the directory of an earlier one is given a suffix instead.
+func (jd JobDirectory) createRun(id string) (JobDirectory, error) {
+	err := os.MkdirAll(jd.GetRunsDir(), os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+
+	name := id
+	for i := 2; ; i++ {
+		err = os.Mkdir(string(jd.GetRun(name)), os.ModePerm)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", id, i)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// the link is replaced with a rename, so latest always points at a run
+	latest := strings.TrimSuffix(string(jd.GetLatest()), "/")
+	tmp := latest + ".tmp"
+	os.Remove(tmp)
+	err = os.Symlink(runsDir+"/"+name, tmp)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tmp, latest)
+	if err != nil {
+		return "", err
+	}
+
+	log.Debug().Msgf("created run %s of job directory %s", name, jd)
+	return jd.GetRun(name), nil
+}
+
 func mkdirIfNotExists(path, name string) (string, error) {
 	path = strings.TrimSpace(path)
 	if path[len(path)-1] != '/' {
@@ -54,6 +209,64 @@ func mkdirIfNotExists(path, name string) (string, error) {
 	return longFileName + "/", os.Mkdir(longFileName, os.ModePerm)
 }
 
-func (jd JobDirectory) ReadData() {
-	//TODO
+// ReadData reads the samples of the data.csv of a run, the columns of runtime metrics are ignored.
+// Datasets written before samples were tagged with a phase have none.
+func (jd JobDirectory) ReadData() ([]dataPoint, error) {
+	f, err := os.Open(jd.GetDataFile())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	points := []dataPoint{}
+	phase := -1
+	lines := bufio.NewScanner(f)
+	for line := 1; lines.Scan(); line++ {
+		// the first two lines are the title and header of the dataset
+		if line == 2 {
+			phase = columnIndex(lines.Text(), phaseColumn)
+		}
+		if line <= 2 || strings.TrimSpace(lines.Text()) == "" {
+			continue
+		}
+
+		point, err := parseDataPoint(lines.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", jd.GetDataFile(), line, err)
+		}
+		if cells := strings.Split(lines.Text(), ","); phase >= 0 && phase < len(cells) {
+			point.Phase = strings.TrimSpace(cells[phase])
+		}
+		points = append(points, point)
+	}
+	return points, lines.Err()
+}
+
+func parseDataPoint(row string) (dataPoint, error) {
+	cells := strings.Split(row, ",")
+	if len(cells) < 6 {
+		return dataPoint{}, fmt.Errorf("expected at least 6 columns, got %d", len(cells))
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(cells[0]))
+	if err != nil {
+		return dataPoint{}, err
+	}
+
+	values := make([]float64, 5)
+	for i := range values {
+		values[i], err = strconv.ParseFloat(strings.TrimSpace(cells[i+1]), 64)
+		if err != nil {
+			return dataPoint{}, err
+		}
+	}
+
+	return dataPoint{
+		Timestamp:      timestamp,
+		IntervalMs:     values[0],
+		CPUPercent:     values[1],
+		MemoryMb:       values[2],
+		DiskWriteKb:    values[3],
+		NetworkWriteKb: values[4],
+	}, nil
 }
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// latencyMetric is the response time of the app measured by the traffic driver, in milliseconds
+const latencyMetric = "latency"
+
+// saveDriverLogs writes the output of the traffic driver to the directory of the run, hey prints
+// the latency of the requests it sent once it is done
+func (j *Job) saveDriverLogs(ctx context.Context, driverID string) error {
+	cli, err := client.NewClientWithOpts()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	logs, err := cli.ContainerLogs(ctx, driverID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	f, err := os.Create(j.Output.GetDriverLogFile())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// the traffic driver does not run with a tty, so its output is multiplexed
+	_, err = stdcopy.StdCopy(f, f, logs)
+	return err
+}
+
+// readLatency reads the latency summary hey printed to the driver log of a run, or nil when the
+// run has no driver log or hey did not finish
+func readLatency(dir JobDirectory) (*metricSummary, error) {
+	f, err := os.Open(dir.GetDriverLogFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseHeyLatency(f)
+}
+
+// parseHeyLatency reads the summary and latency distribution of the output of hey, which are
+// printed in seconds:
+//
+//	Summary:
+//	  Slowest:	0.0236 secs
+//	  Average:	0.0021 secs
+//	Latency distribution:
+//	  50% in 0.0017 secs
+//	  95% in 0.0040 secs
+//	Status code distribution:
+//	  [200]	1000 responses
+func parseHeyLatency(r io.Reader) (*metricSummary, error) {
+	summary := &metricSummary{Metric: latencyMetric}
+	found := map[string]bool{}
+
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		var field string
+		var err error
+		switch {
+		case strings.HasPrefix(line, "Average:"):
+			field = "average"
+			summary.Mean, err = parseHeySeconds(strings.TrimPrefix(line, "Average:"))
+		case strings.HasPrefix(line, "Slowest:"):
+			field = "slowest"
+			summary.Max, err = parseHeySeconds(strings.TrimPrefix(line, "Slowest:"))
+		case strings.HasPrefix(line, "50% in "):
+			field = "p50"
+			summary.P50, err = parseHeySeconds(strings.TrimPrefix(line, "50% in "))
+		case strings.HasPrefix(line, "95% in "):
+			field = "p95"
+			summary.P95, err = parseHeySeconds(strings.TrimPrefix(line, "95% in "))
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "responses"):
+			field = "responses"
+			var responses int
+			responses, err = strconv.Atoi(strings.Fields(line)[1])
+			summary.Samples += responses
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the %s latency from hey: %w", field, err)
+		}
+		found[field] = true
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+
+	if !found["average"] || !found["p95"] {
+		return nil, nil
+	}
+	return summary, nil
+}
+
+// parseHeySeconds reads a duration hey printed in seconds as milliseconds
+func parseHeySeconds(value string) (float64, error) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "secs")), 64)
+	return seconds * 1000, err
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+const heyOutput = `+ sleep 20
++ ./hey -c 10 -q 5 -z 100s http://app:8000/
+
+Summary:
+  Total:	100.0114 secs
+  Slowest:	0.0236 secs
+  Fastest:	0.0007 secs
+  Average:	0.0021 secs
+  Requests/sec:	49.8862
+
+Response time histogram:
+  0.001 [1]	|
+  0.003 [4300]	|■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■■
+
+Latency distribution:
+  10% in 0.0011 secs
+  50% in 0.0017 secs
+  95% in 0.0040 secs
+  99% in 0.0101 secs
+
+Status code distribution:
+  [200]	4980 responses
+  [503]	20 responses
+`
+
+func TestParseHeyLatency(t *testing.T) {
+	latency, err := parseHeyLatency(strings.NewReader(heyOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latency == nil {
+		t.Fatal("expected the latency to be read")
+	}
+
+	expected := metricSummary{Metric: latencyMetric, Samples: 5000, Mean: 2.1, P50: 1.7, P95: 4, Max: 23.6}
+	near := func(a, b float64) bool { return a-b < 1e-9 && b-a < 1e-9 }
+	if latency.Samples != expected.Samples || !near(latency.Mean, expected.Mean) || !near(latency.P50, expected.P50) ||
+		!near(latency.P95, expected.P95) || !near(latency.Max, expected.Max) {
+		t.Errorf("expected %+v, got %+v", expected, *latency)
+	}
+
+	// hey only prints its summary once it finishes
+	latency, err = parseHeyLatency(strings.NewReader("+ sleep 20\n"))
+	if err != nil || latency != nil {
+		t.Errorf("expected no latency from a driver that did not finish, got %v, %v", latency, err)
+	}
+}
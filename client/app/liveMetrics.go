@@ -0,0 +1,175 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// live holds the most recent samples of the jobs in a batch
+var live = &liveMetrics{
+	series: map[liveKey]*liveSeries{},
+}
+
+type liveKey struct {
+	job, service string
+}
+
+type liveSeries struct {
+	phase   string
+	running bool
+	point   dataPoint
+	runtime map[string]float64
+}
+
+// liveMetrics publishes the current samples of each job in the prometheus text exposition format
+type liveMetrics struct {
+	mu     sync.Mutex
+	series map[liveKey]*liveSeries
+}
+
+// ServeLiveMetrics exposes the samples of running jobs on http://<address>/metrics
+func ServeLiveMetrics(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", live)
+	go func() {
+		err := http.Serve(listener, mux)
+		if err != nil {
+			log.Warn().Msgf("live metrics server stopped: %v", err)
+		}
+	}()
+
+	log.Info().Msgf("Serving live job metrics on http://%s/metrics", listener.Addr().String())
+	return nil
+}
+
+// update publishes the latest sample of a job, labelled with the phase it was read in. The end of
+// an automatic warmup is only known once the job is done, until then samples after traffic starts
+// are steady.
+func (l *liveMetrics) update(job, service string, point dataPoint, runtime *runtimeScraper, values []*float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	series := &liveSeries{
+		phase:   point.Phase,
+		running: true,
+		point:   point,
+		runtime: map[string]float64{},
+	}
+	if runtime != nil {
+		for i, metric := range runtime.metrics {
+			if values[i] != nil {
+				series.runtime[metric] = *values[i]
+			}
+		}
+	}
+	l.series[liveKey{job, service}] = series
+}
+
+// finish marks a job as no longer running, its last sample is kept
+func (l *liveMetrics) finish(job, service string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	series, ok := l.series[liveKey{job, service}]
+	if ok {
+		series.phase = cooldownPhase
+		series.running = false
+	}
+}
+
+type liveMetric struct {
+	name, help, kind string
+	value            func(*liveSeries) float64
+}
+
+var liveMetricDefinitions = []liveMetric{
+	{"agent_p_job_running", "Whether agent-p is collecting data for the job.", "gauge", func(s *liveSeries) float64 {
+		if s.running {
+			return 1
+		}
+		return 0
+	}},
+	{"agent_p_sample_timestamp_seconds", "Time the most recent sample was read.", "gauge", func(s *liveSeries) float64 {
+		return float64(s.point.Timestamp.UnixNano()) / 1e9
+	}},
+	{"agent_p_sample_interval_seconds", "Measured time between the two most recent samples.", "gauge", func(s *liveSeries) float64 {
+		return s.point.IntervalMs / 1000
+	}},
+	{"agent_p_cpu_utilization_percent", "CPU utilization of the container.", "gauge", func(s *liveSeries) float64 {
+		return s.point.CPUPercent
+	}},
+	{"agent_p_memory_usage_bytes", "Memory usage of the container.", "gauge", func(s *liveSeries) float64 {
+		return s.point.MemoryMb * 1024 * 1024
+	}},
+	{"agent_p_disk_write_bytes", "Bytes written to disk by the container.", "gauge", func(s *liveSeries) float64 {
+		return s.point.DiskWriteKb * 1024
+	}},
+	{"agent_p_network_transmit_bytes", "Bytes sent over the network by the container since the previous sample.", "gauge", func(s *liveSeries) float64 {
+		return s.point.NetworkWriteKb * 1024
+	}},
+}
+
+func (l *liveMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	l.write(w)
+}
+
+func (l *liveMetrics) write(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := make([]liveKey, 0, len(l.series))
+	for key := range l.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].job == keys[j].job {
+			return keys[i].service < keys[j].service
+		}
+		return keys[i].job < keys[j].job
+	})
+
+	for _, metric := range liveMetricDefinitions {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for _, key := range keys {
+			series := l.series[key]
+			fmt.Fprintf(w, "%s{%s} %g\n", metric.name, liveLabels(key, series.phase), metric.value(series))
+		}
+	}
+
+	fmt.Fprint(w, "# HELP agent_p_runtime_metric Runtime metric scraped from the app.\n# TYPE agent_p_runtime_metric gauge\n")
+	for _, key := range keys {
+		series := l.series[key]
+		names := make([]string, 0, len(series.runtime))
+		for name := range series.runtime {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(w, "agent_p_runtime_metric{%s,metric=\"%s\"} %g\n", liveLabels(key, series.phase), escapeLabel(name), series.runtime[name])
+		}
+	}
+}
+
+func liveLabels(key liveKey, phase string) string {
+	return fmt.Sprintf("job=\"%s\",phase=\"%s\",service=\"%s\"", escapeLabel(key.job), phase, escapeLabel(key.service))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveMetricsExposition(t *testing.T) {
+	l := &liveMetrics{series: map[liveKey]*liveSeries{}}
+	scraper := &runtimeScraper{metrics: []string{"go_goroutines", "go_memstats_heap_alloc_bytes"}}
+	goroutines := 12.0
+	point := dataPoint{
+		Timestamp:  time.Unix(10, 0),
+		CPUPercent: 42.5,
+		MemoryMb:   2,
+		Phase:      steadyPhase,
+	}
+
+	l.update(`my "job"`, appName, point, scraper, []*float64{&goroutines, nil})
+	out := &strings.Builder{}
+	l.write(out)
+
+	for _, expect := range []string{
+		`agent_p_job_running{job="my \"job\"",phase="steady",service="app"} 1`,
+		`agent_p_cpu_utilization_percent{job="my \"job\"",phase="steady",service="app"} 42.5`,
+		`agent_p_memory_usage_bytes{job="my \"job\"",phase="steady",service="app"} 2.097152e+06`,
+		`agent_p_runtime_metric{job="my \"job\"",phase="steady",service="app",metric="go_goroutines"} 12`,
+	} {
+		if !strings.Contains(out.String(), expect) {
+			t.Errorf("Expected exposition to contain %s, got:\n%s", expect, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "go_memstats_heap_alloc_bytes") {
+		t.Error("Runtime metrics that were not scraped should not be exposed")
+	}
+
+	l.finish(`my "job"`, appName)
+	out.Reset()
+	l.write(out)
+	if !strings.Contains(out.String(), `agent_p_job_running{job="my \"job\"",phase="cooldown",service="app"} 0`) {
+		t.Errorf("Expected finished job to no longer be running, got:\n%s", out.String())
+	}
+}
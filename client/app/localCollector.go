@@ -0,0 +1,308 @@
+package app
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	localServer               = "local"
+	defaultLocalCollectorPort = 18443
+
+	// collectorName is the compose service that forwards the connections of the app to the
+	// local collector running in agent-p, so agents can reach it on the default https port
+	collectorName  = "collector"
+	collectorImage = "alpine/socat:latest"
+
+	collectorCertDir  = ".local-collector"
+	collectorCertFile = "cert.pem"
+	collectorKeyFile  = "key.pem"
+	// collectorCertPath is where the certificate of the local collector is mounted in the app container
+	collectorCertPath = trustedCertDir + "/collector.pem"
+
+	// localLicenseKey is used when no license key is given, agents only check its length
+	localLicenseKey = "0000000000000000000000000000000000000000"
+)
+
+// collectorRequest is a single call an agent made to the local collector
+type collectorRequest struct {
+	Time              time.Time
+	Method            string
+	Bytes             int64
+	UncompressedBytes int64
+}
+
+// localCollector is a mock New Relic collector that speaks just enough of the agent protocol to
+// keep agents connected, and records every payload they send to it.
+type localCollector struct {
+	mu       sync.Mutex
+	server   *http.Server
+	requests []collectorRequest
+	runs     int
+}
+
+// startLocalCollector serves the local collector over https on every interface of the host, so
+// that containers can reach it through the docker host gateway.
+func startLocalCollector(port uint, workspace string) (*localCollector, error) {
+	cert, err := collectorCertificate(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &localCollector{}
+	c.server = &http.Server{
+		Handler:   c,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+
+	go func() {
+		err := c.server.ServeTLS(listener, "", "")
+		if err != nil && err != http.ErrServerClosed {
+			log.Warn().Msgf("local collector stopped: %v", err)
+		}
+	}()
+
+	log.Debug().Msgf("local collector listening on %s", listener.Addr().String())
+	return c, nil
+}
+
+// Close stops the local collector
+func (c *localCollector) Close() {
+	c.server.Close()
+}
+
+// begin clears the recorded requests before a job starts
+func (c *localCollector) begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = nil
+}
+
+// end writes the requests recorded while the job ran to its directory
+func (c *localCollector) end(dir JobDirectory) error {
+	return writeCollectorData(dir, c.take())
+}
+
+// take returns the requests recorded since the job started
+func (c *localCollector) take() []collectorRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	requests := c.requests
+	c.requests = nil
+	return requests
+}
+
+func (c *localCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	method := r.URL.Query().Get("method")
+	if method == "" {
+		method = strings.Trim(r.URL.Path, "/")
+	}
+
+	wire := &countingReader{r: r.Body}
+	uncompressed, err := payloadSize(wire, r.Header.Get("Content-Encoding"))
+	if err != nil {
+		log.Debug().Msgf("local collector could not decompress %s payload: %v", method, err)
+	}
+	// read anything left over so the size on the wire is complete
+	io.Copy(io.Discard, wire)
+
+	c.mu.Lock()
+	c.requests = append(c.requests, collectorRequest{
+		Time:              received,
+		Method:            method,
+		Bytes:             wire.n,
+		UncompressedBytes: uncompressed,
+	})
+	if method == "connect" {
+		c.runs++
+	}
+	runID := c.runs
+	c.mu.Unlock()
+
+	log.Debug().Msgf("local collector received %s: %d bytes", method, wire.n)
+
+	w.Header().Set("Content-Type", "application/json")
+	switch method {
+	case "preconnect":
+		fmt.Fprintf(w, `{"return_value":{"redirect_host":"%s"}}`, collectorName)
+	case "connect":
+		fmt.Fprintf(w, `{"return_value":{"agent_run_id":"%d","entity_guid":"agent-p-local-%d","collect_traces":true,"collect_errors":true,"collect_analytics_events":true,"collect_custom_events":true,"collect_error_events":true,"collect_span_events":true,"data_report_period":60}}`, runID, runID)
+	default:
+		fmt.Fprint(w, `{"return_value":null}`)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// payloadSize returns the size of a payload once decompressed
+func payloadSize(body io.Reader, encoding string) (int64, error) {
+	var reader io.Reader = body
+	switch strings.ToLower(encoding) {
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		reader = gz
+	case "deflate":
+		z, err := zlib.NewReader(body)
+		if err != nil {
+			return 0, err
+		}
+		defer z.Close()
+		reader = z
+	}
+	return io.Copy(io.Discard, reader)
+}
+
+// collectorMethodSummary aggregates the requests an agent made for a single collector method
+type collectorMethodSummary struct {
+	Method            string
+	Count             int
+	Bytes             int64
+	UncompressedBytes int64
+	MeanInterval      time.Duration
+}
+
+func summarizeCollectorRequests(requests []collectorRequest) []collectorMethodSummary {
+	byMethod := map[string][]collectorRequest{}
+	for _, request := range requests {
+		byMethod[request.Method] = append(byMethod[request.Method], request)
+	}
+
+	summaries := []collectorMethodSummary{}
+	for method, calls := range byMethod {
+		summary := collectorMethodSummary{
+			Method: method,
+			Count:  len(calls),
+		}
+		for _, call := range calls {
+			summary.Bytes += call.Bytes
+			summary.UncompressedBytes += call.UncompressedBytes
+		}
+		if len(calls) > 1 {
+			summary.MeanInterval = calls[len(calls)-1].Time.Sub(calls[0].Time) / time.Duration(len(calls)-1)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Method < summaries[j].Method
+	})
+	return summaries
+}
+
+// writeCollectorData writes every request the agent of a job made to the local collector, followed
+// by a summary of each collector method.
+func writeCollectorData(dir JobDirectory, requests []collectorRequest) error {
+	f, err := os.Create(dir.GetCollectorFile())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data := bufio.NewWriter(f)
+	data.WriteString("Timestamp, Method, Payload Bytes, Uncompressed Payload Bytes\n")
+	for _, request := range requests {
+		data.WriteString(fmt.Sprintf("%s,%s,%d,%d\n", request.Time.Format(time.RFC3339Nano), request.Method, request.Bytes, request.UncompressedBytes))
+	}
+
+	data.WriteString("\nMethod, Requests, Payload Bytes, Uncompressed Payload Bytes, Mean Interval s\n")
+	for _, summary := range summarizeCollectorRequests(requests) {
+		data.WriteString(fmt.Sprintf("%s,%d,%d,%d,%.3f\n", summary.Method, summary.Count, summary.Bytes, summary.UncompressedBytes, summary.MeanInterval.Seconds()))
+	}
+
+	return data.Flush()
+}
+
+// collectorCertificate loads the self signed certificate of the local collector from the workspace,
+// creating it the first time it is needed.
+func collectorCertificate(workspace string) (tls.Certificate, error) {
+	dir, err := mkdirIfNotExists(workspace, collectorCertDir)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certFile := dir + collectorCertFile
+	keyFile := dir + collectorKeyFile
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err == nil {
+		return cert, nil
+	}
+
+	log.Debug().Msgf("creating a certificate for the local collector in %s...", dir)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: collectorName, Organization: []string{"agent-p"}},
+		DNSNames:              []string{collectorName, "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocalCollectorProtocol(t *testing.T) {
+	c := &localCollector{}
+	c.begin()
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/agent_listener/invoke_raw_method?method=preconnect", bytes.NewBufferString("[]")))
+	preconnect := struct {
+		ReturnValue struct {
+			RedirectHost string `json:"redirect_host"`
+		} `json:"return_value"`
+	}{}
+	err := json.Unmarshal(rec.Body.Bytes(), &preconnect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preconnect.ReturnValue.RedirectHost != collectorName {
+		t.Errorf("Expected agents to be redirected to %s, got %s", collectorName, preconnect.ReturnValue.RedirectHost)
+	}
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/agent_listener/invoke_raw_method?method=connect", bytes.NewBufferString("[{}]")))
+	connect := struct {
+		ReturnValue struct {
+			RunID string `json:"agent_run_id"`
+		} `json:"return_value"`
+	}{}
+	err = json.Unmarshal(rec.Body.Bytes(), &connect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connect.ReturnValue.RunID == "" {
+		t.Error("Expected connect to return an agent run id")
+	}
+
+	payload := &bytes.Buffer{}
+	gz := gzip.NewWriter(payload)
+	gz.Write(bytes.Repeat([]byte("metric"), 100))
+	gz.Close()
+	wireSize := int64(payload.Len())
+
+	req := httptest.NewRequest(http.MethodPost, "/agent_listener/invoke_raw_method?method=metric_data", payload)
+	req.Header.Set("Content-Encoding", "gzip")
+	c.ServeHTTP(httptest.NewRecorder(), req)
+
+	requests := c.take()
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 recorded requests, got %d", len(requests))
+	}
+	metrics := requests[2]
+	if metrics.Method != "metric_data" || metrics.Bytes != wireSize || metrics.UncompressedBytes != 600 {
+		t.Errorf("Incorrect metric_data request recorded: %+v", metrics)
+	}
+
+	summaries := summarizeCollectorRequests(requests)
+	if len(summaries) != 3 || summaries[0].Method != "connect" || summaries[0].Count != 1 {
+		t.Errorf("Incorrect summary of collector requests: %+v", summaries)
+	}
+}
+
+func TestParseComposeContainers(t *testing.T) {
+	array := []byte(`[{"ID":"a1","Service":"app","State":"running"},{"ID":"d1","Service":"driver","State":"running"}]`)
+	lines := []byte("{\"ID\":\"a1\",\"Service\":\"app\"}\n{\"ID\":\"d1\",\"Service\":\"driver\"}\n")
+
+	for _, out := range [][]byte{array, lines} {
+		containers, err := parseComposeContainers(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(containers) != 2 || containers[0].Service != appName || containers[1].ID != "d1" {
+			t.Errorf("Incorrect containers parsed from %s: %+v", out, containers)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+)
+
+// Version of agent-p, set when it is built with -ldflags "-X agent-p/app.Version=v1.2.3"
+var Version = ""
+
+// runManifest records what a run of a job measured, and what it was measured with, so runs can
+// be compared over time
+type runManifest struct {
+	Job          string                 `json:"job"`
+	Run          string                 `json:"run"`
+	AgentVersion string                 `json:"agent-p-version"`
+	Started      time.Time              `json:"started"`
+	Finished     *time.Time             `json:"finished,omitempty"`
+	Config       map[string]interface{} `json:"config"`
+	Settings     map[string]interface{} `json:"settings,omitempty"`
+	Images       map[string]imageInfo   `json:"images,omitempty"`
+	Docker       dockerInfo             `json:"docker"`
+	Host         hostInfo               `json:"host"`
+}
+
+// imageInfo identifies the image a container ran. Digest is empty for images that were built
+// locally and never pushed to or pulled from a registry.
+type imageInfo struct {
+	Image  string `json:"image"`
+	ID     string `json:"id"`
+	Digest string `json:"digest,omitempty"`
+}
+
+type dockerInfo struct {
+	Version    string `json:"version,omitempty"`
+	APIVersion string `json:"api-version,omitempty"`
+}
+
+// hostInfo describes the machine the containers of a job ran on, which is the host of the docker
+// engine. The cpu model is read from the machine agent-p runs on, which is the same host unless
+// the engine is remote.
+type hostInfo struct {
+	Hostname      string `json:"hostname"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	Kernel        string `json:"kernel,omitempty"`
+	CPUModel      string `json:"cpu-model,omitempty"`
+	CPUs          int    `json:"cpus"`
+	MemoryBytes   int64  `json:"memory-bytes,omitempty"`
+	CgroupVersion string `json:"cgroup-version,omitempty"`
+}
+
+// agentVersion is the version agent-p was built with, or the module version it was installed at
+func agentVersion() string {
+	if Version != "" {
+		return Version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return "devel-" + setting.Value
+		}
+	}
+	return "devel"
+}
+
+// startManifest writes the manifest of a run as the job starts. A manifest is still written when
+// the docker engine can not be described, and the error is returned alongside it.
+func (j *Job) startManifest(ctx context.Context, started time.Time) error {
+	j.manifest = &runManifest{
+		Job:          j.Name,
+		Run:          j.Output.runID(),
+		AgentVersion: agentVersion(),
+		Started:      started,
+		Host:         localHost(),
+	}
+
+	var err error
+	j.manifest.Config, err = redactedConfig(j.Config)
+	if err != nil {
+		return err
+	}
+	if j.Settings != nil {
+		j.manifest.Settings, err = redactedSettings(*j.Settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	engineErr := j.manifest.describeEngine(ctx)
+	err = j.manifest.write(j.Output)
+	if err != nil {
+		return err
+	}
+	return engineErr
+}
+
+// recordImages adds the images of a job's containers to its manifest once they have started
+func (j *Job) recordImages(ctx context.Context, appID, driverID string) error {
+	if j.manifest == nil {
+		return nil
+	}
+
+	cli, err := client.NewClientWithOpts()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	j.manifest.Images = map[string]imageInfo{}
+	for service, id := range map[string]string{appName: appID, driverName: driverID} {
+		j.manifest.Images[service], err = containerImage(ctx, cli, id)
+		if err != nil {
+			return err
+		}
+	}
+	return j.manifest.write(j.Output)
+}
+
+// finishManifest records when a job stopped running
+func (j *Job) finishManifest(finished time.Time) error {
+	if j.manifest == nil {
+		return nil
+	}
+	j.manifest.Finished = &finished
+	return j.manifest.write(j.Output)
+}
+
+func (m *runManifest) write(dir JobDirectory) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dir.GetManifestFile(), content, 0644)
+}
+
+// describeEngine records the version of the docker engine, and the host it runs on
+func (m *runManifest) describeEngine(ctx context.Context) error {
+	cli, err := client.NewClientWithOpts()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return err
+	}
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.Docker = dockerInfo{Version: version.Version, APIVersion: version.APIVersion}
+	m.Host.OS = info.OperatingSystem
+	m.Host.Arch = info.Architecture
+	m.Host.Kernel = info.KernelVersion
+	m.Host.CPUs = info.NCPU
+	m.Host.MemoryBytes = info.MemTotal
+	if info.CgroupVersion != "" {
+		m.Host.CgroupVersion = info.CgroupVersion
+	}
+	return nil
+}
+
+// redactedConfig is the config of a job as it was run, without the secrets it was given
+func redactedConfig(run Run) (map[string]interface{}, error) {
+	config, err := decodedConfig(run)
+	if err != nil {
+		return nil, err
+	}
+	return redactValues(config).(map[string]interface{}), nil
+}
+
+// redactedSettings is the top-level config a job was run with, along with the endpoint of the
+// server it resolved to, without the license key or the other jobs
+func redactedSettings(cfg RunConfig) (map[string]interface{}, error) {
+	cfg.LicenseKey = ""
+	cfg.Runs = nil
+	settings, err := decodedConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	delete(settings, "jobs")
+
+	settings["endpoint"], err = decodedConfig(cfg.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return redactValues(settings).(map[string]interface{}), nil
+}
+
+// decodedConfig converts part of a config to the values it is written as in the config file
+func decodedConfig(value interface{}) (map[string]interface{}, error) {
+	content, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]interface{}{}
+	err = yaml.Unmarshal(content, &config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// redactValues redacts the strings of a decoded config, redacting the yaml itself could leave a
+// replacement that is no longer a string
+func redactValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return secrets.redact(v)
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = redactValues(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValues(child)
+		}
+	}
+	return value
+}
+
+// containerImage looks up the image a container was created from, the digest of a tag can change
+// between runs while the digest of an image can not
+func containerImage(ctx context.Context, cli *client.Client, containerID string) (imageInfo, error) {
+	container, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return imageInfo{}, err
+	}
+
+	info := imageInfo{ID: container.Image}
+	if container.Config != nil {
+		info.Image = container.Config.Image
+	}
+
+	image, _, err := cli.ImageInspectWithRaw(ctx, container.Image)
+	if err != nil {
+		return imageInfo{}, err
+	}
+	if len(image.RepoDigests) > 0 {
+		info.Digest = image.RepoDigests[0]
+	}
+	return info, nil
+}
+
+// localHost describes the machine agent-p runs on, the docker engine fills in what it knows
+// about its own host
+func localHost() hostInfo {
+	hostname, _ := os.Hostname()
+	host := hostInfo{
+		Hostname:      hostname,
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		CPUs:          runtime.NumCPU(),
+		CgroupVersion: localCgroupVersion(),
+	}
+
+	kernel, err := os.ReadFile(filepath.Join(procRoot, "sys", "kernel", "osrelease"))
+	if err == nil {
+		host.Kernel = strings.TrimSpace(string(kernel))
+	}
+
+	cpuinfo, err := os.Open(filepath.Join(procRoot, "cpuinfo"))
+	if err == nil {
+		defer cpuinfo.Close()
+		host.CPUModel = parseCPUModel(bufio.NewScanner(cpuinfo))
+	}
+	return host
+}
+
+// parseCPUModel reads the cpu model from /proc/cpuinfo, which names it "model name" on x86 and
+// "Model" on some arm hosts
+func parseCPUModel(lines *bufio.Scanner) string {
+	model := ""
+	for lines.Scan() {
+		key, value, found := strings.Cut(lines.Text(), ":")
+		if !found {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "model name":
+			return strings.TrimSpace(value)
+		case "Model":
+			model = strings.TrimSpace(value)
+		}
+	}
+	return model
+}
+
+func localCgroupVersion() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	if err == nil {
+		return "2"
+	}
+	return "1"
+}
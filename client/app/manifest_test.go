@@ -0,0 +1,142 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactedConfig(t *testing.T) {
+	secrets.add("manifest-secret-value")
+	run := Run{
+		Name: "web",
+		App: App{
+			Image:   "web:latest",
+			EnvVars: map[string]string{"API_KEY": "manifest-secret-value", "MODE": "fast"},
+		},
+	}
+
+	config, err := redactedConfig(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["name"] != "web" {
+		t.Errorf("expected the config to have the name of the job, got %v", config["name"])
+	}
+
+	app, ok := config["app"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected the config to have an app, got %v", config["app"])
+	}
+	env, ok := app["environment-variables"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected the app to have environment variables, got %v", app["environment-variables"])
+	}
+	if strings.Contains(env["API_KEY"].(string), "manifest-secret-value") {
+		t.Errorf("expected the secret to be redacted, got %v", env["API_KEY"])
+	}
+	if env["MODE"] != "fast" {
+		t.Errorf("expected values that are not secret to be kept, got %v", env["MODE"])
+	}
+}
+
+func TestRedactedSettings(t *testing.T) {
+	cfg := RunConfig{
+		Server:     "eu",
+		LicenseKey: "settings-license-key",
+		Runs: []Run{{
+			Name: "web",
+			App:  App{Image: "web:latest", Port: UintPointer(8000)},
+		}},
+	}
+	errs := cfg.defaultAndValidate()
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	settings, err := redactedSettings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings["new-relic-server"] != "eu" {
+		t.Errorf("expected the settings to have the server, got %v", settings)
+	}
+	if _, ok := settings["jobs"]; ok {
+		t.Errorf("expected the settings to leave out the jobs, got %v", settings["jobs"])
+	}
+	content, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "settings-license-key") {
+		t.Errorf("expected the license key to be left out, got %s", content)
+	}
+
+	endpoint, ok := settings["endpoint"].(map[string]interface{})
+	if !ok || endpoint["host"] != "collector.eu01.nr-data.net" || endpoint["tls"] != true {
+		t.Errorf("expected the endpoint of the eu server, got %v", settings["endpoint"])
+	}
+}
+
+func TestParseCPUModel(t *testing.T) {
+	tests := []struct {
+		cpuinfo string
+		model   string
+	}{
+		{"processor\t: 0\nvendor_id\t: GenuineIntel\nmodel\t\t: 85\nmodel name\t: Intel(R) Xeon(R) Platinum 8259CL CPU @ 2.50GHz\n", "Intel(R) Xeon(R) Platinum 8259CL CPU @ 2.50GHz"},
+		{"processor\t: 0\nBogoMIPS\t: 108.00\n\nHardware\t: BCM2835\nModel\t\t: Raspberry Pi 4 Model B Rev 1.4\n", "Raspberry Pi 4 Model B Rev 1.4"},
+		{"processor\t: 0\n", ""},
+	}
+
+	for _, test := range tests {
+		model := parseCPUModel(bufio.NewScanner(strings.NewReader(test.cpuinfo)))
+		if model != test.model {
+			t.Errorf("expected cpu model %q, got %q", test.model, model)
+		}
+	}
+}
+
+func TestAgentVersion(t *testing.T) {
+	defer func(version string) { Version = version }(Version)
+
+	Version = "v1.2.3"
+	if agentVersion() != "v1.2.3" {
+		t.Errorf("expected the version set at build time, got %s", agentVersion())
+	}
+
+	Version = ""
+	if agentVersion() == "" {
+		t.Error("expected a version when none was set at build time")
+	}
+}
+
+func TestFinishManifest(t *testing.T) {
+	dir := JobDirectory(t.TempDir() + "/")
+	started := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	job := &Job{
+		Name:     "web",
+		Output:   dir,
+		manifest: &runManifest{Job: "web", Started: started},
+	}
+
+	err := job.finishManifest(started.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(dir.GetManifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := runManifest{}
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Finished == nil || manifest.Finished.Sub(manifest.Started) != time.Minute {
+		t.Errorf("expected the manifest to record a run of a minute, got %s", content)
+	}
+}
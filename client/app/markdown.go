@@ -0,0 +1,143 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MarkdownFile is the markdown summary of the last batch, in the jobs workspace
+const MarkdownFile = "summary.md"
+
+const (
+	regressionMarker  = "🔴"
+	improvementMarker = "🟢"
+)
+
+// markdownMetrics are the metrics in the markdown summary, for each of them lower is better
+var markdownMetrics = map[string]string{
+	cpuMetric:     "CPU %",
+	memoryMetric:  "Memory Mb",
+	networkMetric: "Network Kb",
+	latencyMetric: "Latency ms",
+}
+
+// MarkdownSummary is the path of the markdown summary of the jobs workspace in the current
+// working directory
+func MarkdownSummary() string {
+	return "./" + JobsDir + "/" + MarkdownFile
+}
+
+// WriteMarkdownSummary writes a compact markdown summary of the runs of a batch, short enough to
+// be posted as a pull request comment. Each job has a table of its metrics, and how they changed
+// from its baseline, with changes beyond threshold percent marked as regressions or improvements.
+func (b Batch) WriteMarkdownSummary(file string, threshold float64) error {
+	jobs := []*reportJob{}
+	for _, job := range b {
+		if job.Output == "" {
+			jobs = append(jobs, &reportJob{Name: job.Name, Baseline: job.Baseline, Status: notRunStatus})
+			continue
+		}
+
+		rj, err := readReportJob(job.Config, job.Output)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, rj)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	regressions := writeMarkdownSummary(f, jobs, threshold)
+	log.Info().Msgf("wrote a markdown summary of %d jobs with %d regressions to %s", len(jobs), regressions, file)
+	return nil
+}
+
+// writeMarkdownSummary writes the summary of each job, and returns the number of regressions
+func writeMarkdownSummary(w io.Writer, jobs []*reportJob, threshold float64) int {
+	byName := map[string]*reportJob{}
+	for _, job := range jobs {
+		byName[job.Name] = job
+	}
+
+	body := &strings.Builder{}
+	regressions := 0
+	for _, job := range jobs {
+		fmt.Fprintf(body, "### %s\n\n", job.Name)
+		details := []string{job.Status}
+		if job.Run != "" {
+			details = append(details, fmt.Sprintf("run `%s`", job.Run))
+		}
+		baseline := byName[job.Baseline]
+		if baseline != nil {
+			details = append(details, fmt.Sprintf("baseline `%s`", job.Baseline))
+		}
+		fmt.Fprintf(body, "%s\n\n", strings.Join(details, " · "))
+
+		if len(job.summaries) == 0 {
+			body.WriteString("No results were collected.\n\n")
+			continue
+		}
+
+		body.WriteString("| Metric | Mean | p95 | Δ Mean | Δ p95 |\n")
+		body.WriteString("| ------ | ---: | --: | -----: | ----: |\n")
+		for _, metric := range reportMetrics {
+			label, ok := markdownMetrics[metric.Name]
+			summary := job.summaries[metric.Name]
+			if !ok || summary == nil {
+				continue
+			}
+
+			meanChange, p95Change := "", ""
+			if baseline != nil && baseline.summaries[metric.Name] != nil {
+				base := baseline.summaries[metric.Name]
+				var meanRegressed, p95Regressed bool
+				meanChange, meanRegressed = markChange(base.Mean, summary.Mean, threshold)
+				p95Change, p95Regressed = markChange(base.P95, summary.P95, threshold)
+				if meanRegressed || p95Regressed {
+					regressions++
+				}
+			}
+			fmt.Fprintf(body, "| %s | %.2f | %.2f | %s | %s |\n", label, summary.Mean, summary.P95, meanChange, p95Change)
+		}
+		body.WriteString("\n")
+	}
+
+	fmt.Fprintf(w, "## agent-p results\n\n")
+	if regressions == 1 {
+		fmt.Fprintf(w, "**1 metric regressed** by more than %g%% from its baseline.\n\n", threshold)
+	} else if regressions > 1 {
+		fmt.Fprintf(w, "**%d metrics regressed** by more than %g%% from their baseline.\n\n", regressions, threshold)
+	} else {
+		fmt.Fprintf(w, "No metric regressed by more than %g%% from its baseline.\n\n", threshold)
+	}
+	io.WriteString(w, body.String())
+	fmt.Fprintf(w, "%s worse than the baseline by more than %g%%, %s better by more than %g%%\n", regressionMarker, threshold, improvementMarker, threshold)
+	return regressions
+}
+
+// markChange formats the change of a metric from its baseline, marking it when it is beyond the
+// threshold. It returns whether the metric regressed.
+func markChange(baseline, value, threshold float64) (string, bool) {
+	if baseline == 0 {
+		return "", false
+	}
+
+	change := (value - baseline) / baseline * 100
+	formatted := fmt.Sprintf("%+.1f%%", change)
+	if math.Abs(change) <= threshold {
+		return formatted, false
+	}
+	if change > 0 {
+		return formatted + " " + regressionMarker, true
+	}
+	return formatted + " " + improvementMarker, false
+}
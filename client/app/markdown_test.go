@@ -0,0 +1,54 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdownSummary(t *testing.T) {
+	jobs := []*reportJob{
+		{
+			Name:   "without-agent",
+			Status: succeededStatus,
+			Run:    "20261019T120000Z",
+			summaries: map[string]*metricSummary{
+				cpuMetric:     {Mean: 10, P95: 20},
+				memoryMetric:  {Mean: 100, P95: 110},
+				latencyMetric: {Mean: 2, P95: 4},
+			},
+		},
+		{
+			Name:     "with-agent",
+			Baseline: "without-agent",
+			Status:   succeededStatus,
+			Run:      "20261019T120000Z",
+			summaries: map[string]*metricSummary{
+				cpuMetric:     {Mean: 12, P95: 21},
+				memoryMetric:  {Mean: 80, P95: 111},
+				latencyMetric: {Mean: 2.1, P95: 4.1},
+			},
+		},
+		{Name: "never-ran", Baseline: "without-agent", Status: notRunStatus},
+	}
+
+	var out bytes.Buffer
+	regressions := writeMarkdownSummary(&out, jobs, 10)
+	summary := out.String()
+
+	if regressions != 1 {
+		t.Errorf("expected 1 regression, got %d:\n%s", regressions, summary)
+	}
+	for _, expected := range []string{
+		"**1 metric regressed**",
+		"### with-agent",
+		"| CPU % | 12.00 | 21.00 | +20.0% " + regressionMarker + " | +5.0% |",
+		"| Memory Mb | 80.00 | 111.00 | -20.0% " + improvementMarker + " | +0.9% |",
+		"| Latency ms | 2.10 | 4.10 | +5.0% | +2.5% |",
+		"No results were collected.",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("expected the summary to contain %q, got:\n%s", expected, summary)
+		}
+	}
+}
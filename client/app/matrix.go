@@ -0,0 +1,299 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v3"
+)
+
+// matrixFields are the matrix keys that set a field of the job, any other key only labels the job
+// and is available to templates as {{ .Matrix.key }}
+var matrixFields = map[string][]string{
+	"agent-version": {"agent-version"},
+	"rps":           {"traffic-driver", "traffic", "requests-per-second"},
+	"users":         {"traffic-driver", "traffic", "concurrent-requests"},
+	"duration":      {"traffic-driver", "traffic", "duration"},
+	"endpoint":      {"traffic-driver", "service-endpoint"},
+	"image":         {"app", "image"},
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// expandMatrices replaces each job with a matrix by one job for every combination of its values
+func expandMatrices(root *yaml.Node) error {
+	jobs := mappingValue(documentRoot(root), "jobs")
+	if jobs == nil || jobs.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	expanded := []*yaml.Node{}
+	for _, job := range jobs.Content {
+		matrix := mappingValue(job, "matrix")
+		if matrix == nil {
+			expanded = append(expanded, job)
+			continue
+		}
+
+		combinations, err := matrixCombinations(matrix)
+		if err != nil {
+			return err
+		}
+		err = checkMatrixTemplates(job, matrix)
+		if err != nil {
+			return err
+		}
+		for _, combination := range combinations {
+			expanded = append(expanded, matrixJob(job, combination))
+		}
+	}
+	jobs.Content = expanded
+	return nil
+}
+
+// matrixCombinations returns the cartesian product of the values of a matrix, each combination
+// is a list of key value pairs in the order the keys are written in
+func matrixCombinations(matrix *yaml.Node) ([][][2]string, error) {
+	if matrix.Kind != yaml.MappingNode || len(matrix.Content) == 0 {
+		return nil, fmt.Errorf("config error: matrix on line %d must map keys to lists of values", matrix.Line)
+	}
+
+	combinations := [][][2]string{{}}
+	for i := 0; i+1 < len(matrix.Content); i += 2 {
+		key := matrix.Content[i].Value
+		values := []*yaml.Node{matrix.Content[i+1]}
+		if matrix.Content[i+1].Kind == yaml.SequenceNode {
+			values = matrix.Content[i+1].Content
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("config error: matrix.%s on line %d has no values", key, matrix.Content[i+1].Line)
+		}
+
+		next := [][][2]string{}
+		for _, combination := range combinations {
+			for _, value := range values {
+				if value.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("config error: matrix.%s on line %d must be a list of values", key, value.Line)
+				}
+				c := append(append([][2]string{}, combination...), [2]string{key, value.Value})
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+	return combinations, nil
+}
+
+// checkMatrixTemplates rejects templates of a job that read a key its matrix does not have, which
+// is most likely a typo. Reading a missing key with index would otherwise be silently empty.
+func checkMatrixTemplates(job, matrix *yaml.Node) error {
+	keys := map[string]bool{}
+	for _, key := range matrixKeys(matrix) {
+		keys[key] = true
+	}
+
+	templates := []*yaml.Node{}
+	collectTemplates(job, matrix, &templates)
+	for _, node := range templates {
+		// templates that do not parse are reported when they are executed
+		t, err := template.New("").Parse(node.Value)
+		if err != nil {
+			continue
+		}
+		for _, key := range templateMatrixKeys(t.Tree.Root) {
+			if !keys[key] {
+				return fmt.Errorf("config error: template on line %d, column %d reads matrix.%s, which is not in the matrix of the job, use one of: %s",
+					node.Line, node.Column, key, strings.Join(matrixKeys(matrix), ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// collectTemplates lists the values of a job, other than its matrix, that are templates
+func collectTemplates(node, matrix *yaml.Node, templates *[]*yaml.Node) {
+	if node == matrix {
+		return
+	}
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "{{") {
+		*templates = append(*templates, node)
+	}
+	for _, child := range node.Content {
+		collectTemplates(child, matrix, templates)
+	}
+}
+
+// templateMatrixKeys lists the matrix keys a parsed template reads, either as {{ .Matrix.key }} or
+// as {{ index .Matrix "key" }}
+func templateMatrixKeys(node parse.Node) []string {
+	keys := []string{}
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return keys
+		}
+		for _, child := range n.Nodes {
+			keys = append(keys, templateMatrixKeys(child)...)
+		}
+	case *parse.ActionNode:
+		keys = templateMatrixKeys(n.Pipe)
+	case *parse.IfNode:
+		keys = branchMatrixKeys(&n.BranchNode)
+	case *parse.RangeNode:
+		keys = branchMatrixKeys(&n.BranchNode)
+	case *parse.WithNode:
+		keys = branchMatrixKeys(&n.BranchNode)
+	case *parse.TemplateNode:
+		keys = templateMatrixKeys(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return keys
+		}
+		for _, cmd := range n.Cmds {
+			keys = append(keys, templateMatrixKeys(cmd)...)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) >= 3 && isIdentifier(n.Args[0], "index") && isMatrixField(n.Args[1]) {
+			if key, ok := n.Args[2].(*parse.StringNode); ok {
+				keys = append(keys, key.Text)
+			}
+		}
+		for _, arg := range n.Args {
+			keys = append(keys, templateMatrixKeys(arg)...)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "Matrix" {
+			keys = append(keys, n.Ident[1])
+		}
+	}
+	return keys
+}
+
+func branchMatrixKeys(n *parse.BranchNode) []string {
+	keys := templateMatrixKeys(n.Pipe)
+	keys = append(keys, templateMatrixKeys(n.List)...)
+	return append(keys, templateMatrixKeys(n.ElseList)...)
+}
+
+func isIdentifier(node parse.Node, name string) bool {
+	identifier, ok := node.(*parse.IdentifierNode)
+	return ok && identifier.Ident == name
+}
+
+// isMatrixField is whether a node is the whole matrix, {{ .Matrix }}
+func isMatrixField(node parse.Node) bool {
+	field, ok := node.(*parse.FieldNode)
+	return ok && len(field.Ident) == 1 && field.Ident[0] == "Matrix"
+}
+
+// matrixKeys lists the keys of a matrix in the order they are written in
+func matrixKeys(matrix *yaml.Node) []string {
+	keys := []string{}
+	for i := 0; i+1 < len(matrix.Content); i += 2 {
+		keys = append(keys, matrix.Content[i].Value)
+	}
+	return keys
+}
+
+// matrixJob copies a job for a single combination of its matrix, with a name made unique by the
+// combination and the matrix replaced by its coordinates
+func matrixJob(job *yaml.Node, combination [][2]string) *yaml.Node {
+	clone := cloneNode(job)
+
+	coordinates := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	suffix := []string{}
+	for _, kv := range combination {
+		coordinates.Content = append(coordinates.Content, stringNode(kv[0]), stringNode(kv[1]))
+		suffix = append(suffix, matrixNamePart(kv[0], kv[1]))
+
+		if path, ok := matrixFields[kv[0]]; ok {
+			setMappingPath(clone, path, kv[1])
+		}
+	}
+	setMappingValue(clone, "matrix", coordinates)
+
+	name := mappingValue(clone, "name")
+	if name == nil {
+		name = stringNode("")
+		setMappingValue(clone, "name", name)
+	}
+	setScalar(name, strings.Join(append([]string{name.Value}, suffix...), "-"))
+	return clone
+}
+
+// matrixNamePart makes a matrix coordinate safe to use in a job name, and so in a directory name
+func matrixNamePart(key, value string) string {
+	value = strings.Trim(unsafeNameChars.ReplaceAllString(value, "_"), "_")
+	if value == "" {
+		value = "root"
+	}
+	return fmt.Sprintf("%s-%s", key, value)
+}
+
+// matrixCoordinates reads the coordinates of a job that was expanded from a matrix
+func matrixCoordinates(job *yaml.Node) map[string]string {
+	coordinates := map[string]string{}
+	matrix := mappingValue(job, "matrix")
+	if matrix == nil || matrix.Kind != yaml.MappingNode {
+		return coordinates
+	}
+	for i := 0; i+1 < len(matrix.Content); i += 2 {
+		coordinates[matrix.Content[i].Value] = matrix.Content[i+1].Value
+	}
+	return coordinates
+}
+
+// writeMatrixFile writes the matrix coordinates of a job to its directory, so results can be
+// grouped by them
+func writeMatrixFile(dir JobDirectory, name string, matrix map[string]string) error {
+	content, err := json.MarshalIndent(struct {
+		Job    string            `json:"job"`
+		Matrix map[string]string `json:"matrix"`
+	}{name, matrix}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dir.GetMatrixFile(), content, 0644)
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+}
+
+func cloneNode(node *yaml.Node) *yaml.Node {
+	clone := *node
+	clone.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		clone.Content[i] = cloneNode(child)
+	}
+	return &clone
+}
+
+// setMappingValue sets the value of a key in a yaml mapping, adding the key when it is not set
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, stringNode(key), value)
+}
+
+// setMappingPath sets a scalar nested in yaml mappings, adding the mappings that are missing
+func setMappingPath(node *yaml.Node, path []string, value string) {
+	for _, key := range path[:len(path)-1] {
+		next := mappingValue(node, key)
+		if next == nil || next.Kind != yaml.MappingNode {
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(node, key, next)
+		}
+		node = next
+	}
+	setMappingValue(node, path[len(path)-1], stringNode(value))
+}
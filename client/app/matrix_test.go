@@ -0,0 +1,146 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandMatrices(t *testing.T) {
+	config := `
+jobs:
+  - name: web
+    matrix:
+      agent-version: [v3.18, v3.19]
+      rps: [50, 100]
+      endpoint: [/, /mysql]
+    app:
+      image: my-app:{{ index .Matrix "agent-version" }}
+      service-port: 8000
+    traffic-driver:
+      service-endpoint: /ignored
+  - name: plain
+    app:
+      image: my-app:latest
+`
+
+	root := yaml.Node{}
+	err := yaml.Unmarshal([]byte(config), &root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = prepareConfig(&root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := RunConfig{}
+	err = root.Decode(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.Runs) != 9 {
+		t.Fatalf("expected 8 matrix jobs and 1 plain job, got %d", len(cfg.Runs))
+	}
+
+	first := cfg.Runs[0]
+	if first.Name != "web-agent-version-v3.18-rps-50-endpoint-root" {
+		t.Errorf("unexpected name %s", first.Name)
+	}
+	if first.App.Image != "my-app:v3.18" {
+		t.Errorf("expected image my-app:v3.18, got %s", first.App.Image)
+	}
+	if first.AgentVersion != "v3.18" {
+		t.Errorf("expected agent-version v3.18, got %s", first.AgentVersion)
+	}
+	if first.TrafficDriver.Traffic.Rate == nil || *first.TrafficDriver.Traffic.Rate != 50 {
+		t.Errorf("expected requests-per-second 50, got %v", first.TrafficDriver.Traffic.Rate)
+	}
+	if first.TrafficDriver.Endpoint != "/" {
+		t.Errorf("expected service-endpoint /, got %s", first.TrafficDriver.Endpoint)
+	}
+	if first.Matrix["agent-version"] != "v3.18" || first.Matrix["rps"] != "50" || first.Matrix["endpoint"] != "/" {
+		t.Errorf("unexpected matrix coordinates %v", first.Matrix)
+	}
+
+	last := cfg.Runs[7]
+	if last.Name != "web-agent-version-v3.19-rps-100-endpoint-mysql" {
+		t.Errorf("unexpected name %s", last.Name)
+	}
+
+	seen := map[string]bool{}
+	for _, run := range cfg.Runs {
+		if seen[run.Name] {
+			t.Errorf("name %s is not unique", run.Name)
+		}
+		seen[run.Name] = true
+	}
+
+	if cfg.Runs[8].Name != "plain" || len(cfg.Runs[8].Matrix) != 0 {
+		t.Errorf("a job without a matrix should not change, got %+v", cfg.Runs[8])
+	}
+}
+
+func TestMatrixTemplateKeys(t *testing.T) {
+	tests := []struct {
+		template string
+		column   int
+	}{
+		{"my-app:{{ .Matrix.version }}", 14},
+		{`my-app:{{ index .Matrix "agent-verison" }}`, 14},
+		// a template is not passed because it ranges over something else
+		{`/orange/{{ range $i, $v := .Matrix }}{{ $i }}{{ end }}{{ if .Matrix.rsp }}slow{{ end }}`, 14},
+	}
+	for _, test := range tests {
+		config := "jobs:\n  - name: web\n    matrix:\n      agent-version: [v3.18, v3.19]\n      mode: [fast]\n    app:\n      image: '" + test.template + "'\n"
+		root := yaml.Node{}
+		err := yaml.Unmarshal([]byte(config), &root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = expandMatrices(&root)
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("template on line 7, column %d", test.column)) || !strings.Contains(err.Error(), "agent-version, mode") {
+			t.Errorf("expected an error at the template %s, got %v", test.template, err)
+		}
+	}
+
+	// a key that sets no field and is read by no template labels the jobs
+	config := "jobs:\n  - name: web\n    matrix:\n      mode: [fast, slow]\n    app:\n      image: 'my-app:{{ index .Matrix \"mode\" }}-{{ .Matrix.mode }}'\n  - name: api\n    matrix:\n      region: [us, eu]\n    app:\n      image: my-app:latest\n"
+	root := yaml.Node{}
+	err := yaml.Unmarshal([]byte(config), &root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = expandMatrices(&root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := mappingValue(documentRoot(&root), "jobs")
+	if len(jobs.Content) != 4 || mappingValue(jobs.Content[3], "name").Value != "api-region-eu" {
+		t.Errorf("expected the region to label the jobs it was expanded into, got %d jobs", len(jobs.Content))
+	}
+}
+
+func TestExpandMatricesErrors(t *testing.T) {
+	configs := []string{
+		"jobs:\n  - name: web\n    matrix: [1, 2]\n",
+		"jobs:\n  - name: web\n    matrix:\n      rps: []\n",
+		"jobs:\n  - name: web\n    matrix:\n      rps: [[1]]\n",
+		"jobs:\n  - name: web\n    matrix:\n      rps: [50]\n    traffic-driver:\n      service-endpoint: /{{ .Matrix.rsp }}\n",
+	}
+
+	for _, config := range configs {
+		root := yaml.Node{}
+		err := yaml.Unmarshal([]byte(config), &root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expandMatrices(&root) == nil {
+			t.Errorf("expected an error expanding %q", config)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// oldestVersion is assumed for configs that do not have a version
+const oldestVersion = "0.1.0"
+
+// configMigration rewrites a config written for one version into the format of the next
+type configMigration struct {
+	from    string
+	to      string
+	migrate func(root *yaml.Node) []string
+}
+
+// migrations are applied in order, each one starting from the version the previous one ended on
+var migrations = []configMigration{
+	{
+		from:    "0.1.0",
+		to:      "0.2.0",
+		migrate: migrateIntegerDurations,
+	},
+}
+
+// durationKeys were integer seconds before 0.2.0, they now need a unit
+var durationKeys = map[string]bool{
+	"startup-delay":       true,
+	"duration":            true,
+	"collection-interval": true,
+}
+
+// configVersion returns the version of a config, and the node it is written in
+func configVersion(root *yaml.Node) (string, *yaml.Node) {
+	node := mappingValue(documentRoot(root), "version")
+	if node == nil || node.Kind != yaml.ScalarNode || strings.TrimSpace(node.Value) == "" {
+		return oldestVersion, nil
+	}
+	return strings.TrimSpace(node.Value), node
+}
+
+// migrateConfig rewrites a config into the format of the current version. It returns the version
+// the config was written for, and a description of each change it made.
+func migrateConfig(root *yaml.Node) (string, []string, error) {
+	from, node := configVersion(root)
+	if from == version {
+		return from, nil, nil
+	}
+
+	current := from
+	changes := []string{}
+	for _, m := range migrations {
+		if m.from != current {
+			continue
+		}
+		changes = append(changes, m.migrate(root)...)
+		current = m.to
+	}
+	if current != version {
+		if compareVersions(from, version) > 0 {
+			return from, nil, fmt.Errorf("config version %s is newer than %s, the newest version this agent-p understands, please upgrade agent-p", from, version)
+		}
+		return from, nil, fmt.Errorf("config version %s is not a version of agent-p, the current version is %s", from, version)
+	}
+
+	if node == nil {
+		config := documentRoot(root)
+		config.Content = append([]*yaml.Node{stringNode("version"), stringNode(version)}, config.Content...)
+	} else {
+		setScalar(node, version)
+	}
+	changes = append(changes, fmt.Sprintf("set version from %s to %s", from, version))
+	return from, changes, nil
+}
+
+// migrateIntegerDurations adds the seconds unit to durations written as integers
+func migrateIntegerDurations(root *yaml.Node) []string {
+	changes := []string{}
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if durationKeys[key.Value] && value.Kind == yaml.ScalarNode && isInteger(value.Value) {
+					changes = append(changes, fmt.Sprintf("line %d: %s %s is now %ss", value.Line, joinPath(path, key.Value), value.Value, value.Value))
+					setScalar(value, value.Value+"s")
+					continue
+				}
+				walk(value, joinPath(path, key.Value))
+			}
+		}
+	}
+	walk(root, "")
+	return changes
+}
+
+func isInteger(value string) bool {
+	_, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	return err == nil
+}
+
+// compareVersions compares two dotted versions numerically, versions that can not be parsed sort first
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// MigrateConfig rewrites a config file into the format of the current version, and writes it to
+// output. It returns a description of each change it made, which is empty when the config is
+// already current.
+func MigrateConfig(file, output string) ([]string, error) {
+	cfgBytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	root := yaml.Node{}
+	err = yaml.Unmarshal(cfgBytes, &root)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind == 0 {
+		return nil, fmt.Errorf("config file %s is empty", file)
+	}
+
+	_, changes, err := migrateConfig(&root)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	migrated, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, err
+	}
+	return changes, os.WriteFile(output, migrated, 0664)
+}
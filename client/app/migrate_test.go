@@ -0,0 +1,87 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	config := `version: 0.1.0
+new-relic-server: production
+jobs:
+  - name: example
+    data:
+      collection-interval: 2
+    traffic-driver:
+      startup-delay: 20 # seconds
+      traffic:
+        duration: 100
+        requests-per-second: 100
+`
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(config), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := MigrateConfig(file, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 {
+		t.Errorf("expected 3 durations and the version to change, got %v", changes)
+	}
+
+	migrated, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{"version: " + version, "collection-interval: 2s", "startup-delay: 20s # seconds", "duration: 100s", "requests-per-second: 100\n"} {
+		if !strings.Contains(string(migrated), expect) {
+			t.Errorf("expected the migrated config to contain %q, got:\n%s", expect, migrated)
+		}
+	}
+
+	changes, err = MigrateConfig(file, file)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("a current config should not change, got %v, %v", changes, err)
+	}
+}
+
+func TestMigrateConfigVersions(t *testing.T) {
+	tests := []struct {
+		config  string
+		from    string
+		invalid bool
+	}{
+		{config: "jobs: []\n", from: oldestVersion},
+		{config: "version: " + version + "\n", from: version},
+		{config: "version: 9.0.0\n", from: "9.0.0", invalid: true},
+		{config: "version: 0.1.5\n", from: "0.1.5", invalid: true},
+	}
+
+	for _, test := range tests {
+		root := yaml.Node{}
+		err := yaml.Unmarshal([]byte(test.config), &root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		from, _, err := migrateConfig(&root)
+		if from != test.from {
+			t.Errorf("expected %q to be version %s, got %s", test.config, test.from, from)
+		}
+		if (err != nil) != test.invalid {
+			t.Errorf("unexpected error migrating %q: %v", test.config, err)
+		}
+		if err == nil {
+			if v, _ := configVersion(&root); v != version {
+				t.Errorf("expected %q to be migrated to %s, got %s", test.config, version, v)
+			}
+		}
+	}
+}
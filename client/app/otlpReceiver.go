@@ -0,0 +1,282 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP signals, named after the path they are exported to
+const (
+	tracesSignal  = "traces"
+	metricsSignal = "metrics"
+	logsSignal    = "logs"
+)
+
+// otlpRequest is a single export an OpenTelemetry SDK made to the otlp receiver
+type otlpRequest struct {
+	Time              time.Time
+	Signal            string
+	Bytes             int64
+	UncompressedBytes int64
+	// Items are the spans, metric data points, or log records in the export
+	Items int
+}
+
+// otlpReceiver accepts otlp exports over http, and counts the telemetry the app of a job sends
+type otlpReceiver struct {
+	mu       sync.Mutex
+	server   *http.Server
+	requests []otlpRequest
+}
+
+// startOTLPReceiver serves the otlp/http protocol on every interface of the host, so that
+// containers can reach it through the docker host gateway.
+func startOTLPReceiver(port uint) (*otlpReceiver, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	o := &otlpReceiver{}
+	mux := http.NewServeMux()
+	for _, signal := range []string{tracesSignal, metricsSignal, logsSignal} {
+		signal := signal
+		mux.HandleFunc("/v1/"+signal, func(w http.ResponseWriter, r *http.Request) {
+			o.receive(signal, w, r)
+		})
+	}
+	o.server = &http.Server{Handler: mux}
+
+	go func() {
+		err := o.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Warn().Msgf("otlp receiver stopped: %v", err)
+		}
+	}()
+
+	log.Debug().Msgf("otlp receiver listening on %s", listener.Addr().String())
+	return o, nil
+}
+
+func (o *otlpReceiver) begin() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requests = nil
+}
+
+func (o *otlpReceiver) end(dir JobDirectory) error {
+	return writeOTLPData(dir, o.take())
+}
+
+func (o *otlpReceiver) Close() {
+	o.server.Close()
+}
+
+// take returns the exports recorded since the job started
+func (o *otlpReceiver) take() []otlpRequest {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	requests := o.requests
+	o.requests = nil
+	return requests
+}
+
+func (o *otlpReceiver) receive(signal string, w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	wire, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	payload := wire
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		payload, err = gunzip(wire)
+		if err != nil {
+			log.Debug().Msgf("otlp receiver could not decompress %s export: %v", signal, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+	items, err := countOTLPItems(signal, payload, isJSON)
+	if err != nil {
+		log.Debug().Msgf("otlp receiver could not decode %s export: %v", signal, err)
+	}
+
+	o.mu.Lock()
+	o.requests = append(o.requests, otlpRequest{
+		Time:              received,
+		Signal:            signal,
+		Bytes:             int64(len(wire)),
+		UncompressedBytes: int64(len(payload)),
+		Items:             items,
+	})
+	o.mu.Unlock()
+
+	log.Debug().Msgf("otlp receiver received %d %s: %d bytes", items, signal, len(wire))
+
+	// an empty export response is a success for both encodings
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "{}")
+	} else {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+// countOTLPItems counts the spans, metric data points, or log records of an export. Export
+// requests share their wire format with the data messages of each signal.
+func countOTLPItems(signal string, payload []byte, isJSON bool) (int, error) {
+	unmarshal := func(m proto.Message) error {
+		if isJSON {
+			return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(payload, m)
+		}
+		return proto.Unmarshal(payload, m)
+	}
+
+	count := 0
+	switch signal {
+	case tracesSignal:
+		traces := &tracepb.TracesData{}
+		err := unmarshal(traces)
+		if err != nil {
+			return 0, err
+		}
+		for _, resource := range traces.GetResourceSpans() {
+			for _, scope := range resource.GetScopeSpans() {
+				count += len(scope.GetSpans())
+			}
+		}
+	case metricsSignal:
+		metrics := &metricspb.MetricsData{}
+		err := unmarshal(metrics)
+		if err != nil {
+			return 0, err
+		}
+		for _, resource := range metrics.GetResourceMetrics() {
+			for _, scope := range resource.GetScopeMetrics() {
+				for _, metric := range scope.GetMetrics() {
+					count += metricDataPoints(metric)
+				}
+			}
+		}
+	case logsSignal:
+		logs := &logspb.LogsData{}
+		err := unmarshal(logs)
+		if err != nil {
+			return 0, err
+		}
+		for _, resource := range logs.GetResourceLogs() {
+			for _, scope := range resource.GetScopeLogs() {
+				count += len(scope.GetLogRecords())
+			}
+		}
+	}
+	return count, nil
+}
+
+func metricDataPoints(metric *metricspb.Metric) int {
+	switch {
+	case metric.GetGauge() != nil:
+		return len(metric.GetGauge().GetDataPoints())
+	case metric.GetSum() != nil:
+		return len(metric.GetSum().GetDataPoints())
+	case metric.GetHistogram() != nil:
+		return len(metric.GetHistogram().GetDataPoints())
+	case metric.GetExponentialHistogram() != nil:
+		return len(metric.GetExponentialHistogram().GetDataPoints())
+	case metric.GetSummary() != nil:
+		return len(metric.GetSummary().GetDataPoints())
+	}
+	return 0
+}
+
+// otlpSignalSummary aggregates the exports of a single signal
+type otlpSignalSummary struct {
+	Signal            string
+	Count             int
+	Bytes             int64
+	UncompressedBytes int64
+	Items             int
+}
+
+func summarizeOTLPRequests(requests []otlpRequest) []otlpSignalSummary {
+	bySignal := map[string]*otlpSignalSummary{}
+	for _, request := range requests {
+		summary, ok := bySignal[request.Signal]
+		if !ok {
+			summary = &otlpSignalSummary{Signal: request.Signal}
+			bySignal[request.Signal] = summary
+		}
+		summary.Count++
+		summary.Bytes += request.Bytes
+		summary.UncompressedBytes += request.UncompressedBytes
+		summary.Items += request.Items
+	}
+
+	summaries := []otlpSignalSummary{}
+	for _, summary := range bySignal {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Signal < summaries[j].Signal
+	})
+	return summaries
+}
+
+// writeOTLPData writes every export the app of a job made to the otlp receiver, followed by a
+// summary of each signal.
+func writeOTLPData(dir JobDirectory, requests []otlpRequest) error {
+	f, err := os.Create(dir.GetOTLPFile())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data := bufio.NewWriter(f)
+	data.WriteString("Timestamp, Signal, Payload Bytes, Uncompressed Payload Bytes, Items\n")
+	for _, request := range requests {
+		data.WriteString(fmt.Sprintf("%s,%s,%d,%d,%d\n", request.Time.Format(time.RFC3339Nano), request.Signal, request.Bytes, request.UncompressedBytes, request.Items))
+	}
+
+	data.WriteString("\nSignal, Requests, Payload Bytes, Uncompressed Payload Bytes, Items\n")
+	for _, summary := range summarizeOTLPRequests(requests) {
+		data.WriteString(fmt.Sprintf("%s,%d,%d,%d,%d\n", summary.Signal, summary.Count, summary.Bytes, summary.UncompressedBytes, summary.Items))
+	}
+
+	return data.Flush()
+}
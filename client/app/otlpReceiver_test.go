@@ -0,0 +1,59 @@
+package app
+
+import (
+	"testing"
+
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCountOTLPItems(t *testing.T) {
+	traces, err := proto.Marshal(&tracepb.TracesData{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			ScopeSpans: []*tracepb.ScopeSpans{
+				{Spans: []*tracepb.Span{{Name: "a"}, {Name: "b"}}},
+				{Spans: []*tracepb.Span{{Name: "c"}}},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := proto.Marshal(&metricspb.MetricsData{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Metrics: []*metricspb.Metric{
+					{Name: "gauge", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{{}, {}}}}},
+					{Name: "histogram", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{DataPoints: []*metricspb.HistogramDataPoint{{}}}}},
+				},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := []byte(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"body":{"stringValue":"one"}},{"body":{"stringValue":"two"}}]}]}]}`)
+
+	tests := []struct {
+		signal  string
+		payload []byte
+		isJSON  bool
+		expect  int
+	}{
+		{tracesSignal, traces, false, 3},
+		{metricsSignal, metrics, false, 3},
+		{logsSignal, logs, true, 2},
+	}
+	for _, test := range tests {
+		items, err := countOTLPItems(test.signal, test.payload, test.isJSON)
+		if err != nil {
+			t.Error(err)
+		}
+		if items != test.expect {
+			t.Errorf("Incorrect number of %s counted: expected %d, got %d", test.signal, test.expect, items)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultProfilingPath = "/debug/pprof"
+	defaultCPUDuration   = "30s"
+
+	cpuProfile  = "cpu"
+	heapProfile = "heap"
+)
+
+// profiler captures pprof profiles from a go app while it is under load
+type profiler struct {
+	url         string
+	cpuDuration time.Duration
+	client      *http.Client
+}
+
+func newProfiler(address string, settings *Profiling, cpuDuration time.Duration) *profiler {
+	return &profiler{
+		url:         fmt.Sprintf("http://%s%s", address, settings.Path),
+		cpuDuration: cpuDuration,
+		client:      &http.Client{Timeout: cpuDuration + 30*time.Second},
+	}
+}
+
+// capture records a cpu profile over the steady state load window, then a snapshot of the heap.
+// It gives up as soon as the context is cancelled, like when the job fails.
+func (p *profiler) capture(ctx context.Context, dir JobDirectory, wait time.Duration) {
+	log.Debug().Msgf("waiting %s for the app to reach a steady state before profiling...", wait.String())
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(wait):
+	}
+
+	seconds := int(p.cpuDuration.Seconds())
+	log.Debug().Msgf("capturing a %ds cpu profile from %s...", seconds, p.url)
+	err := p.fetch(ctx, fmt.Sprintf("%s/profile?seconds=%d", p.url, seconds), dir.GetProfile(cpuProfile))
+	if err != nil {
+		log.Warn().Msgf("unable to capture a cpu profile: %v", err)
+	}
+
+	log.Debug().Msgf("capturing a heap profile from %s...", p.url)
+	err = p.fetch(ctx, fmt.Sprintf("%s/heap", p.url), dir.GetProfile(heapProfile))
+	if err != nil {
+		log.Warn().Msgf("unable to capture a heap profile: %v", err)
+	}
+}
+
+func (p *profiler) fetch(ctx context.Context, url, file string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, resp.Body)
+	return err
+}
+
+// diffProfiles writes the difference between the profiles of a job and its baseline, the same
+// way `go tool pprof -diff_base` does, so that the overhead of an agent shows up on its own.
+func diffProfiles(job, baseline JobDirectory) {
+	for _, name := range []string{cpuProfile, heapProfile} {
+		err := diffProfile(baseline.GetProfile(name), job.GetProfile(name), job.GetDiffProfile(name))
+		if err != nil {
+			log.Warn().Msgf("unable to create a %s diff profile in %s: %v", name, job, err)
+			continue
+		}
+		log.Debug().Msgf("created %s", job.GetDiffProfile(name))
+	}
+}
+
+func diffProfile(baseFile, file, diffFile string) error {
+	base, err := readProfile(baseFile)
+	if err != nil {
+		return err
+	}
+
+	current, err := readProfile(file)
+	if err != nil {
+		return err
+	}
+
+	base.Scale(-1)
+	diff, err := profile.Merge([]*profile.Profile{base, current})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(diffFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return diff.Write(f)
+}
+
+func readProfile(file string) (*profile.Profile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return profile.Parse(f)
+}
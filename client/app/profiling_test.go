@@ -0,0 +1,58 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func writeTestProfile(t *testing.T, file string, value int64) {
+	fn := &profile.Function{ID: 1, Name: "main.handler"}
+	loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn}}}
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		Sample:     []*profile.Sample{{Location: []*profile.Location{loc}, Value: []int64{value}}},
+		Location:   []*profile.Location{loc},
+		Function:   []*profile.Function{fn},
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	err = p.Write(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffProfile(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.pprof")
+	current := filepath.Join(dir, "current.pprof")
+	diff := filepath.Join(dir, "diff.pprof")
+	writeTestProfile(t, base, 40)
+	writeTestProfile(t, current, 100)
+
+	err := diffProfile(base, current, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := readProfile(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var total int64
+	for _, sample := range p.Sample {
+		total += sample.Value[0]
+	}
+	if total != 60 {
+		t.Errorf("Incorrect diff profile: expected a total of 60, got %d", total)
+	}
+}
@@ -0,0 +1,370 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// reportMetric is a metric of a run that is summarized and charted in a report, metrics that are
+// not sampled by agent-p are not charted over time
+type reportMetric struct {
+	Name  string
+	Title string
+	Unit  string
+}
+
+var reportMetrics = []reportMetric{
+	{cpuMetric, "CPU Utilization", "%"},
+	{memoryMetric, "Memory Usage", "Mb"},
+	{networkMetric, "Outbound Network Traffic", "Kb"},
+	{diskMetric, "Disk Writes", "Kb"},
+	{latencyMetric, "Latency", "ms"},
+}
+
+// reportJob is the latest run of a job, as it is shown in a report
+type reportJob struct {
+	Name     string
+	Baseline string
+	Run      string
+	Status   string
+	Error    string
+	Manifest *runManifest
+	Config   string
+	Metrics  []reportCell
+	// Matrix are the coordinates of a job expanded from a matrix
+	Matrix map[string]string
+
+	points    []dataPoint
+	summaries map[string]*metricSummary
+}
+
+// reportCell is a summarized metric of a job, and how it changed from the job's baseline
+type reportCell struct {
+	Summary   *metricSummary
+	MeanDelta string
+	P95Delta  string
+}
+
+type reportChart struct {
+	Timeseries template.HTML
+	Summary    template.HTML
+}
+
+type report struct {
+	Config    string
+	Generated time.Time
+	Metrics   []reportMetric
+	Jobs      []*reportJob
+	Charts    []reportChart
+	// MatrixKeys are the keys of the matrices of every job, each is a column of the summary
+	MatrixKeys []string
+}
+
+// Report writes a static html report of the latest run of every job in a config, with summary
+// tables, charts, the config and manifest of each run, and the change of each job from its baseline.
+// A report only reads results, so problems in the config, like a license key that is not set where
+// the report is written, are only warned about.
+func Report(configFile, output string) error {
+	cfg, problems, err := readConfig(configFile)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return problems
+	}
+	for _, problem := range problems {
+		log.Warn().Msgf("config warning: %s", problem)
+	}
+
+	r := report{Config: configFile, Generated: time.Now(), Metrics: reportMetrics}
+	for _, run := range cfg.Runs {
+		job, err := readReportJob(run, ToLocalJobDirectory(run.Name).GetLatest())
+		if err != nil {
+			return err
+		}
+		r.Jobs = append(r.Jobs, job)
+	}
+	r.matrixKeys()
+	r.compareBaselines()
+	r.drawCharts()
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = r.write(f)
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("wrote a report of %d jobs to %s", len(r.Jobs), output)
+	return nil
+}
+
+// readReportJob reads the results of a run of a job, a job that has not run is still listed in
+// the report
+func readReportJob(run Run, dir JobDirectory) (*reportJob, error) {
+	job := &reportJob{Name: run.Name, Baseline: run.Baseline, Status: notRunStatus, summaries: map[string]*metricSummary{}}
+
+	status, err := readJobStatus(dir)
+	if err != nil {
+		return nil, err
+	}
+	if status != nil {
+		job.Status = status.Status
+		job.Error = status.Error
+	}
+
+	job.Manifest, err = readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := redactedConfig(run)
+	if job.Manifest != nil {
+		job.Run = job.Manifest.Run
+		config = job.Manifest.Config
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	job.Config = string(snapshot)
+
+	job.Matrix, err = readMatrixFile(ToLocalJobDirectory(run.Name))
+	if err != nil {
+		return nil, err
+	}
+	if job.Matrix == nil {
+		job.Matrix = run.Matrix
+	}
+
+	latency, err := readLatency(dir)
+	if err != nil {
+		return nil, err
+	}
+	if latency != nil {
+		job.summaries[latencyMetric] = latency
+	}
+
+	job.points, err = dir.ReadData()
+	if errors.Is(err, fs.ErrNotExist) {
+		return job, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range summarizeSamples(job.points) {
+		summary := summary
+		job.summaries[summary.Metric] = &summary
+	}
+	return job, nil
+}
+
+// readManifest reads the manifest of a run, or nil when it has none
+func readManifest(dir JobDirectory) (*runManifest, error) {
+	content, err := os.ReadFile(dir.GetManifestFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &runManifest{}
+	err = json.Unmarshal(content, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", dir.GetManifestFile(), err)
+	}
+	return manifest, nil
+}
+
+// readMatrixFile reads the matrix coordinates of a job, or nil when it was not expanded from a matrix
+func readMatrixFile(dir JobDirectory) (map[string]string, error) {
+	content, err := os.ReadFile(dir.GetMatrixFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := struct {
+		Matrix map[string]string `json:"matrix"`
+	}{}
+	err = json.Unmarshal(content, &matrix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", dir.GetMatrixFile(), err)
+	}
+	return matrix.Matrix, nil
+}
+
+// matrixKeys lists the matrix keys of every job in the report, so jobs can be compared by them
+func (r *report) matrixKeys() {
+	seen := map[string]bool{}
+	for _, job := range r.Jobs {
+		for key := range job.Matrix {
+			if !seen[key] {
+				seen[key] = true
+				r.MatrixKeys = append(r.MatrixKeys, key)
+			}
+		}
+	}
+	sort.Strings(r.MatrixKeys)
+}
+
+// compareBaselines fills in the metrics of each job, along with their change from its baseline
+func (r *report) compareBaselines() {
+	jobs := map[string]*reportJob{}
+	for _, job := range r.Jobs {
+		jobs[job.Name] = job
+	}
+
+	for _, job := range r.Jobs {
+		baseline := jobs[job.Baseline]
+		for _, metric := range reportMetrics {
+			cell := reportCell{Summary: job.summaries[metric.Name]}
+			if baseline != nil && cell.Summary != nil {
+				if base := baseline.summaries[metric.Name]; base != nil {
+					cell.MeanDelta = formatDelta(base.Mean, cell.Summary.Mean)
+					cell.P95Delta = formatDelta(base.P95, cell.Summary.P95)
+				}
+			}
+			job.Metrics = append(job.Metrics, cell)
+		}
+	}
+}
+
+// formatDelta is the relative change from a baseline value
+func formatDelta(baseline, value float64) string {
+	if baseline == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+.1f%%", (value-baseline)/baseline*100)
+}
+
+func (r *report) drawCharts() {
+	names := make([]string, len(r.Jobs))
+	for i, job := range r.Jobs {
+		names[i] = job.Name
+	}
+
+	for _, metric := range reportMetrics {
+		value := sampleValue(metric.Name)
+		series := []chartSeries{}
+		summaries := make([]*metricSummary, len(r.Jobs))
+		for i, job := range r.Jobs {
+			summaries[i] = job.summaries[metric.Name]
+			if len(job.points) > 0 && value != nil {
+				series = append(series, timeseriesSeries(job.Name, job.points, value))
+			}
+		}
+		chart := reportChart{
+			Summary: graphComparativeSummaryStatistics(metric.Title+" by Job", metric.Unit, names, summaries),
+		}
+		if value != nil {
+			chart.Timeseries = graphComparativeTimeseries(metric.Title, metric.Unit, series)
+		}
+		r.Charts = append(r.Charts, chart)
+	}
+}
+
+// formatReportTime formats a time in a report, the finish of a run is optional
+func formatReportTime(t interface{}) string {
+	switch t := t.(type) {
+	case time.Time:
+		return t.Format(time.RFC1123)
+	case *time.Time:
+		if t != nil {
+			return t.Format(time.RFC1123)
+		}
+	}
+	return ""
+}
+
+func (r *report) write(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"number": func(value float64) string { return fmt.Sprintf("%.2f", value) },
+	"time":   formatReportTime,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>agent-p report: {{.Config}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 1100px; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; font-size: 13px; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+th { background: #f5f5f5; }
+.delta { color: #666; font-size: 11px; }
+.failed, .interrupted { color: #c0392b; }
+pre { background: #f7f7f7; padding: 1em; overflow-x: auto; font-size: 12px; }
+details { margin: 1em 0; }
+</style>
+</head>
+<body>
+<h1>agent-p report</h1>
+<p>The latest run of each job in <code>{{.Config}}</code>, generated {{time .Generated}}.</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th rowspan="2">Job</th><th rowspan="2">Run</th><th rowspan="2">Status</th><th rowspan="2">Baseline</th>
+{{- range .MatrixKeys}}<th rowspan="2">{{.}}</th>{{end}}
+{{- range .Metrics}}<th colspan="2">{{.Title}} {{.Unit}}</th>{{end}}</tr>
+<tr>{{range .Metrics}}<th>mean</th><th>p95</th>{{end}}</tr>
+{{- range $job := .Jobs}}
+<tr><td>{{.Name}}</td><td>{{.Run}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Baseline}}</td>
+{{- range $.MatrixKeys}}<td>{{index $job.Matrix .}}</td>{{end}}
+{{- range .Metrics}}
+{{- if .Summary}}<td>{{number .Summary.Mean}} <span class="delta">{{.MeanDelta}}</span></td><td>{{number .Summary.P95}} <span class="delta">{{.P95Delta}}</span></td>
+{{- else}}<td>-</td><td>-</td>{{end}}
+{{- end}}</tr>
+{{- end}}
+</table>
+<p class="delta">Changes are relative to the baseline of each job.</p>
+
+<h2>Charts</h2>
+{{range .Charts}}{{if .Timeseries}}<div>{{.Timeseries}}</div>{{end}}
+<div>{{.Summary}}</div>
+{{end}}
+
+<h2>Jobs</h2>
+{{range .Jobs}}
+<h3>{{.Name}}</h3>
+{{- if .Error}}<p class="failed">{{.Error}}</p>{{end}}
+{{- with .Manifest}}
+<table>
+<tr><td>run</td><td>{{.Run}}</td></tr>
+<tr><td>agent-p version</td><td>{{.AgentVersion}}</td></tr>
+<tr><td>started</td><td>{{time .Started}}</td></tr>
+{{- if .Finished}}<tr><td>finished</td><td>{{time .Finished}}</td></tr>{{end}}
+{{- range $service, $image := .Images}}<tr><td>{{$service}} image</td><td>{{$image.Image}} {{if $image.Digest}}{{$image.Digest}}{{else}}{{$image.ID}}{{end}}</td></tr>{{end}}
+<tr><td>docker</td><td>{{.Docker.Version}}</td></tr>
+<tr><td>host</td><td>{{.Host.Hostname}}, {{.Host.OS}} {{.Host.Arch}}, kernel {{.Host.Kernel}}</td></tr>
+<tr><td>cpu</td><td>{{.Host.CPUs}} x {{.Host.CPUModel}}</td></tr>
+<tr><td>cgroup version</td><td>{{.Host.CgroupVersion}}</td></tr>
+</table>
+{{- end}}
+<details><summary>config</summary><pre>{{.Config}}</pre></details>
+{{end}}
+</body>
+</html>
+`))
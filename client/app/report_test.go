@@ -0,0 +1,162 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestRun writes the data of a run of a job in the jobs workspace of the working directory
+func writeTestRun(t *testing.T, name string, cpu float64) {
+	dir := ToLocalJobDirectory(name)
+	err := os.MkdirAll(string(dir), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir.GetCompose(), []byte("services: {}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := &Job{Name: name, Directory: dir}
+	job.Output, err = dir.createRun("20261019T120000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := &strings.Builder{}
+	data.WriteString("Timeseries Data Measuring the Perfomance of Job " + name + "\n")
+	data.WriteString("Timestamp, Sample Interval ms, CPU utilization %, Memory Usage Mb, Disk Write Kb, Outbound Network Traffic Kb\n")
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(data, "%s,1000.000,%.3f,64.000,0.000,2.000\n", start.Add(time.Duration(i)*time.Second).Format(time.RFC3339Nano), cpu)
+	}
+	err = os.WriteFile(job.Output.GetDataFile(), []byte(data.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job.manifest = &runManifest{Job: name, Run: job.Output.runID(), AgentVersion: "v1.0.0", Started: start}
+	err = job.finishManifest(start.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeJobStatus(job, newJobResult(name, nil, time.Minute), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	writeTestRun(t, "without-agent", 10)
+	writeTestRun(t, "with-agent", 12)
+
+	r := report{Config: "config.yaml", Generated: time.Now(), Metrics: reportMetrics}
+	for _, run := range []Run{{Name: "without-agent"}, {Name: "with-agent", Baseline: "without-agent"}, {Name: "never-ran"}} {
+		job, err := readReportJob(run, ToLocalJobDirectory(run.Name).GetLatest())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Jobs = append(r.Jobs, job)
+	}
+	r.compareBaselines()
+	r.drawCharts()
+
+	if r.Jobs[1].Status != succeededStatus || r.Jobs[1].Manifest == nil || r.Jobs[1].Run != "20261019T120000Z" {
+		t.Errorf("expected the latest run of with-agent to be read, got %+v", r.Jobs[1])
+	}
+	if r.Jobs[1].Metrics[0].MeanDelta != "+20.0%" {
+		t.Errorf("expected the cpu of with-agent to be 20%% higher than its baseline, got %q", r.Jobs[1].Metrics[0].MeanDelta)
+	}
+	if r.Jobs[2].Status != notRunStatus || r.Jobs[2].Metrics[0].Summary != nil {
+		t.Errorf("expected a job that never ran to have no results, got %+v", r.Jobs[2])
+	}
+
+	var out bytes.Buffer
+	err = r.write(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	for _, expected := range []string{"<svg", "<polyline", "with-agent", "20.0%", "v1.0.0"} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected the report to contain %q", expected)
+		}
+	}
+	if strings.Contains(html, "&lt;svg") {
+		t.Error("expected the charts to be embedded, not escaped")
+	}
+}
+
+func TestReportWithoutLicenseKey(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv(licenseKeyVar, "")
+
+	config := `version: 0.2.0
+new-relic-server: production
+jobs:
+  - name: web
+    matrix:
+      rps: [50, 100]
+    app:
+      image: my-app:latest
+      service-port: 8000
+`
+	err = os.WriteFile("config.yaml", []byte(config), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"web-rps-50", "web-rps-100"} {
+		writeTestRun(t, name, float64(10+i))
+		err = writeMatrixFile(ToLocalJobDirectory(name), name, map[string]string{"rps": strings.TrimPrefix(name, "web-rps-")})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the results can be reported by someone without the license key the jobs ran with
+	err = Report("config.yaml", "report.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile("report.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(content)
+	for _, expected := range []string{`<th rowspan="2">rps</th>`, "<td>web-rps-100</td>", "<td>100</td>"} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected the report to contain %q", expected)
+		}
+	}
+}
+
+func TestNiceCeiling(t *testing.T) {
+	tests := map[float64]float64{0: 1, 0.3: 0.5, 7: 10, 12: 20, 23: 25, 250: 250, 251: 500}
+	for value, expected := range tests {
+		if ceiling := niceCeiling(value); ceiling != expected {
+			t.Errorf("expected the ceiling of %g to be %g, got %g", value, expected, ceiling)
+		}
+	}
+}
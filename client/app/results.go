@@ -0,0 +1,355 @@
+package app
+
+import (
+	"agent-p/analysis"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// ResultsFile is the database in the jobs workspace that every run of every job is recorded in
+const ResultsFile = "results.db"
+
+// Metrics summarized for each run
+const (
+	cpuMetric     = "cpu"
+	memoryMetric  = "memory"
+	diskMetric    = "disk"
+	networkMetric = "network"
+)
+
+const resultsSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id              INTEGER PRIMARY KEY,
+	job             TEXT NOT NULL,
+	run             TEXT NOT NULL,
+	status          TEXT NOT NULL,
+	started         TEXT NOT NULL,
+	finished        TEXT NOT NULL,
+	agent_p_version TEXT NOT NULL,
+	agent_version   TEXT NOT NULL,
+	app_image       TEXT NOT NULL,
+	app_digest      TEXT NOT NULL,
+	UNIQUE (job, run)
+);
+CREATE TABLE IF NOT EXISTS samples (
+	run_id           INTEGER NOT NULL REFERENCES runs (id),
+	timestamp        TEXT NOT NULL,
+	interval_ms      REAL NOT NULL,
+	cpu_percent      REAL NOT NULL,
+	memory_mb        REAL NOT NULL,
+	disk_write_kb    REAL NOT NULL,
+	network_write_kb REAL NOT NULL,
+	phase            TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS samples_run ON samples (run_id);
+CREATE TABLE IF NOT EXISTS summaries (
+	run_id  INTEGER NOT NULL REFERENCES runs (id),
+	metric  TEXT NOT NULL,
+	samples INTEGER NOT NULL,
+	mean    REAL NOT NULL,
+	p50     REAL NOT NULL,
+	p95     REAL NOT NULL,
+	max     REAL NOT NULL,
+	PRIMARY KEY (run_id, metric)
+);
+`
+
+// sampleMetric is a metric that is read from each sample of a run
+type sampleMetric struct {
+	name  string
+	value func(dataPoint) float64
+}
+
+var sampleMetrics = []sampleMetric{
+	{cpuMetric, func(p dataPoint) float64 { return p.CPUPercent }},
+	{memoryMetric, func(p dataPoint) float64 { return p.MemoryMb }},
+	{diskMetric, func(p dataPoint) float64 { return p.DiskWriteKb }},
+	{networkMetric, func(p dataPoint) float64 { return p.NetworkWriteKb }},
+}
+
+// sampleValue reads a metric from a sample, metrics that are not sampled, like latency, have none
+func sampleValue(metric string) func(dataPoint) float64 {
+	for _, m := range sampleMetrics {
+		if m.name == metric {
+			return m.value
+		}
+	}
+	return nil
+}
+
+// values reads the metric from each sample
+func (m sampleMetric) values(points []dataPoint) []float64 {
+	values := make([]float64, len(points))
+	for i, point := range points {
+		values[i] = m.value(point)
+	}
+	return values
+}
+
+// ResultsDatabase is the path of the results database of the jobs workspace in the current
+// working directory
+func ResultsDatabase() string {
+	return "./" + JobsDir + "/" + ResultsFile
+}
+
+// resultsDB stores the samples and summary statistics of every run of a job
+type resultsDB struct {
+	db *sql.DB
+}
+
+func openResults(file string) (*resultsDB, error) {
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(resultsSchema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create the tables of %s: %w", file, err)
+	}
+
+	err = migrateResults(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to update the tables of %s: %w", file, err)
+	}
+	return &resultsDB{db: db}, nil
+}
+
+// migrateResults adds the columns that were added to the schema since a database was created
+func migrateResults(db *sql.DB) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('samples')")
+	if err != nil {
+		return err
+	}
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if !columns["phase"] {
+		_, err = db.Exec("ALTER TABLE samples ADD COLUMN phase TEXT NOT NULL DEFAULT ''")
+	}
+	return err
+}
+
+func (r *resultsDB) Close() error {
+	return r.db.Close()
+}
+
+// metricSummary are the summary statistics of one metric of a run
+type metricSummary struct {
+	Metric  string
+	Samples int
+	Mean    float64
+	P50     float64
+	P95     float64
+	Max     float64
+}
+
+// summarizeSamples calculates the summary statistics of each metric of a run from the samples
+// in its steady state
+func summarizeSamples(points []dataPoint) []metricSummary {
+	points = steadyState(points)
+	summaries := []metricSummary{}
+	if len(points) == 0 {
+		return summaries
+	}
+	for _, metric := range sampleMetrics {
+		summaries = append(summaries, newMetricSummary(metric.name, analysis.Summarize(metric.values(points))))
+	}
+	return summaries
+}
+
+func newMetricSummary(metric string, summary analysis.Summary) metricSummary {
+	return metricSummary{
+		Metric:  metric,
+		Samples: summary.Samples,
+		Mean:    summary.Mean,
+		P50:     summary.Median,
+		P95:     summary.P95,
+		Max:     summary.Max,
+	}
+}
+
+// record stores the samples and summary statistics of the run of a job, replacing any that were
+// recorded for the same run before
+func (r *resultsDB) record(job *Job, result jobResult, points []dataPoint) error {
+	manifest := job.manifest
+	if manifest == nil {
+		manifest = &runManifest{Run: job.Output.runID()}
+	}
+	finished := manifest.Started.Add(result.Duration)
+	if manifest.Finished != nil {
+		finished = *manifest.Finished
+	}
+	app := manifest.Images[appName]
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range []string{
+		"DELETE FROM samples WHERE run_id IN (SELECT id FROM runs WHERE job = ? AND run = ?)",
+		"DELETE FROM summaries WHERE run_id IN (SELECT id FROM runs WHERE job = ? AND run = ?)",
+		"DELETE FROM runs WHERE job = ? AND run = ?",
+	} {
+		_, err = tx.Exec(statement, job.Name, manifest.Run)
+		if err != nil {
+			return err
+		}
+	}
+
+	row, err := tx.Exec(
+		"INSERT INTO runs (job, run, status, started, finished, agent_p_version, agent_version, app_image, app_digest) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		job.Name, manifest.Run, result.Status, manifest.Started.Format(time.RFC3339Nano), finished.Format(time.RFC3339Nano),
+		manifest.AgentVersion, job.Config.AgentVersion, app.Image, app.label(),
+	)
+	if err != nil {
+		return err
+	}
+	runID, err := row.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, point := range points {
+		_, err = tx.Exec(
+			"INSERT INTO samples (run_id, timestamp, interval_ms, cpu_percent, memory_mb, disk_write_kb, network_write_kb, phase) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			runID, point.Timestamp.Format(time.RFC3339Nano), point.IntervalMs, point.CPUPercent, point.MemoryMb, point.DiskWriteKb, point.NetworkWriteKb, point.Phase,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, summary := range summarizeSamples(points) {
+		_, err = tx.Exec(
+			"INSERT INTO summaries (run_id, metric, samples, mean, p50, p95, max) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			runID, summary.Metric, summary.Samples, summary.Mean, summary.P50, summary.P95, summary.Max,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// label identifies an image by its digest, or by its id when it has none
+func (i imageInfo) label() string {
+	if i.Digest != "" {
+		return i.Digest
+	}
+	return i.ID
+}
+
+// recordResults stores the results of the run of a job in the results database, when there is one
+func (r *resultsDB) recordResults(job *Job, result jobResult) error {
+	if r == nil || job.Output == "" {
+		return nil
+	}
+
+	points, err := job.Output.ReadData()
+	if errors.Is(err, fs.ErrNotExist) {
+		points = nil
+	} else if err != nil {
+		return err
+	}
+	return r.record(job, result, points)
+}
+
+// runHistory is the summary of one run of a job
+type runHistory struct {
+	Run          string
+	Status       string
+	Started      time.Time
+	AgentVersion string // version of the agent the app ran
+	Image        string
+	Digest       string
+	CPU          *metricSummary
+	Memory       *metricSummary
+}
+
+// history lists every recorded run of a job, from oldest to newest
+func (r *resultsDB) history(job string) ([]runHistory, error) {
+	rows, err := r.db.Query(`
+		SELECT runs.run, runs.status, runs.started, runs.agent_version, runs.app_image, runs.app_digest,
+			summaries.metric, summaries.samples, summaries.mean, summaries.p50, summaries.p95, summaries.max
+		FROM runs LEFT JOIN summaries ON summaries.run_id = runs.id AND summaries.metric IN (?, ?)
+		WHERE runs.job = ?
+		ORDER BY runs.started, runs.run`, cpuMetric, memoryMetric, job)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []runHistory{}
+	for rows.Next() {
+		var run runHistory
+		var started string
+		var metric sql.NullString
+		var samples sql.NullInt64
+		var mean, p50, p95, max sql.NullFloat64
+		err = rows.Scan(&run.Run, &run.Status, &started, &run.AgentVersion, &run.Image, &run.Digest, &metric, &samples, &mean, &p50, &p95, &max)
+		if err != nil {
+			return nil, err
+		}
+		run.Started, err = time.Parse(time.RFC3339Nano, started)
+		if err != nil {
+			return nil, err
+		}
+
+		// a run has a row for each of its metrics
+		if len(runs) == 0 || runs[len(runs)-1].Run != run.Run {
+			runs = append(runs, run)
+		}
+		if !metric.Valid {
+			continue
+		}
+		summary := &metricSummary{
+			Metric:  metric.String,
+			Samples: int(samples.Int64),
+			Mean:    mean.Float64,
+			P50:     p50.Float64,
+			P95:     p95.Float64,
+			Max:     max.Float64,
+		}
+		if metric.String == cpuMetric {
+			runs[len(runs)-1].CPU = summary
+		} else {
+			runs[len(runs)-1].Memory = summary
+		}
+	}
+	return runs, rows.Err()
+}
+
+// openExistingResults opens the results database without creating one
+func openExistingResults(file string) (*resultsDB, error) {
+	_, err := os.Stat(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ConfigError{fmt.Errorf("no results have been recorded in %s yet, run a batch of jobs first", file)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openResults(file)
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadData(t *testing.T) {
+	dir := JobDirectory(t.TempDir() + "/")
+	data := "Timeseries Data Measuring the Perfomance of Job web\n" +
+		"Timestamp, Sample Interval ms, CPU utilization %, Memory Usage Mb, Disk Write Kb, Outbound Network Traffic Kb, go_goroutines\n" +
+		"2026-10-19T12:00:00.5Z,0.000,12.500,64.000,0.000,1.500,12\n" +
+		"2026-10-19T12:00:01.5Z,1000.000,15.000,65.000,4.000,2.000,\n"
+	err := os.WriteFile(dir.GetDataFile(), []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := dir.ReadData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(points))
+	}
+	expected := dataPoint{
+		Timestamp:      time.Date(2026, 10, 19, 12, 0, 1, 500000000, time.UTC),
+		IntervalMs:     1000,
+		CPUPercent:     15,
+		MemoryMb:       65,
+		DiskWriteKb:    4,
+		NetworkWriteKb: 2,
+	}
+	if !points[1].Timestamp.Equal(expected.Timestamp) || points[1].CPUPercent != expected.CPUPercent || points[1].MemoryMb != expected.MemoryMb || points[1].NetworkWriteKb != expected.NetworkWriteKb {
+		t.Errorf("expected %+v, got %+v", expected, points[1])
+	}
+
+	err = os.WriteFile(dir.GetDataFile(), []byte(data+"2026-10-19T12:00:02Z,1000.000,oops,65.000,4.000,2.000\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dir.ReadData()
+	if err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("expected an error on line 5, got %v", err)
+	}
+}
+
+func testRun(cpu, memory float64, samples int) []dataPoint {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	points := make([]dataPoint, samples)
+	for i := range points {
+		points[i] = dataPoint{Timestamp: start.Add(time.Duration(i) * time.Second), CPUPercent: cpu, MemoryMb: memory}
+	}
+	return points
+}
+
+func TestResultsHistory(t *testing.T) {
+	db, err := openResults(filepath.Join(t.TempDir(), ResultsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	runs := []struct {
+		id     string
+		digest string
+		cpu    float64
+		points []dataPoint
+		status string
+	}{
+		{"20261017T120000Z", "web@sha256:aaaaaaaaaaaaaaaaaaaa", 10, testRun(10, 100, 20), succeededStatus},
+		{"20261018T120000Z", "web@sha256:bbbbbbbbbbbbbbbbbbbb", 0, nil, failedStatus},
+		{"20261019T120000Z", "web@sha256:bbbbbbbbbbbbbbbbbbbb", 12, testRun(12, 90, 20), succeededStatus},
+	}
+	for i, run := range runs {
+		started := time.Date(2026, 10, 17+i, 12, 0, 0, 0, time.UTC)
+		job := &Job{
+			Name:   "web",
+			Config: Run{AgentVersion: fmt.Sprintf("v3.%d", 18+i)},
+			manifest: &runManifest{
+				Run:          run.id,
+				Started:      started,
+				AgentVersion: "v1.0.0",
+				Images:       map[string]imageInfo{appName: {Image: "web:latest", Digest: run.digest}},
+			},
+		}
+		err = db.record(job, jobResult{Name: "web", Status: run.status, Duration: time.Minute}, run.points)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// recording a run again replaces it
+	err = db.record(&Job{Name: "web", manifest: &runManifest{Run: runs[0].id, Started: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}},
+		jobResult{Name: "web", Status: succeededStatus}, testRun(10, 100, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := db.history("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 runs, got %d", len(history))
+	}
+	if history[1].CPU != nil || history[1].Status != failedStatus {
+		t.Errorf("expected the failed run to have no summary, got %+v", history[1])
+	}
+	if history[2].CPU == nil || history[2].CPU.P95 != 12 || history[2].Memory.P95 != 90 {
+		t.Errorf("expected the last run to have a p95 cpu of 12 and memory of 90, got %+v", history[2])
+	}
+
+	var out bytes.Buffer
+	err = writeHistory(&out, history, DigestLabel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and 3 runs, got:\n%s", out.String())
+	}
+	if !strings.Contains(lines[3], "bbbbbbbbbbbb ") || !strings.Contains(lines[3], "+20.0%") || !strings.Contains(lines[3], "-10.0%") {
+		t.Errorf("expected the last run to be compared against the first, got:\n%s", out.String())
+	}
+
+	// runs are labelled by the agent the app ran, not by the version of agent-p
+	out.Reset()
+	err = writeHistory(&out, history, VersionLabel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "v3.20 ") || strings.Contains(out.String(), "v1.0.0") {
+		t.Errorf("expected the last run to be labelled by its agent version, got:\n%s", out.String())
+	}
+
+	empty, err := db.history("api")
+	if err != nil || len(empty) != 0 {
+		t.Errorf("expected no runs of an unknown job, got %v, %v", empty, err)
+	}
+}
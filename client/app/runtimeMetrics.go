@@ -0,0 +1,230 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/rs/zerolog/log"
+)
+
+// Runtime metrics formats
+const (
+	prometheusFormat = "prometheus"
+	expvarFormat     = "expvar"
+)
+
+var (
+	// defaultRuntimeMetrics are collected when no metrics are listed, they are the ones
+	// exposed by the go runtime for each format
+	defaultRuntimeMetrics = map[string][]string{
+		prometheusFormat: {
+			"go_goroutines",
+			"go_memstats_heap_alloc_bytes",
+			"go_memstats_heap_inuse_bytes",
+			"go_memstats_alloc_bytes_total",
+			"go_gc_duration_seconds_sum",
+			"go_gc_duration_seconds_count",
+		},
+		expvarFormat: {
+			"memstats.HeapAlloc",
+			"memstats.HeapInuse",
+			"memstats.TotalAlloc",
+			"memstats.Mallocs",
+			"memstats.NumGC",
+			"memstats.PauseTotalNs",
+		},
+	}
+	defaultRuntimeMetricsPath = map[string]string{
+		prometheusFormat: "/metrics",
+		expvarFormat:     "/debug/vars",
+	}
+)
+
+// runtimeScraper reads the in process runtime metrics an app exposes over http
+type runtimeScraper struct {
+	url     string
+	format  string
+	metrics []string
+	client  *http.Client
+}
+
+// newRuntimeScraper creates a scraper for the runtime metrics endpoint of an app container
+func newRuntimeScraper(cli *client.Client, appID string, settings *RuntimeMetrics, timeout time.Duration) (*runtimeScraper, error) {
+	address, err := publishedAddress(cli, appID, *settings.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("http://%s%s", address, settings.Path)
+	log.Debug().Msgf("scraping %s runtime metrics from %s", settings.Format, url)
+	return &runtimeScraper{
+		url:     url,
+		format:  settings.Format,
+		metrics: settings.Metrics,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// publishedAddress finds the address on the host that a port of a container is published to
+func publishedAddress(cli *client.Client, containerID string, port uint) (string, error) {
+	info, err := cli.ContainerInspect(context.Background(), containerID)
+	if err != nil {
+		return "", err
+	}
+
+	if info.NetworkSettings != nil {
+		bindings := info.NetworkSettings.Ports[nat.Port(fmt.Sprintf("%d/tcp", port))]
+		for _, binding := range bindings {
+			if binding.HostPort == "" {
+				continue
+			}
+
+			host := binding.HostIP
+			if host == "" || host == "0.0.0.0" {
+				host = "127.0.0.1"
+			}
+			return fmt.Sprintf("%s:%s", host, binding.HostPort), nil
+		}
+	}
+
+	return "", fmt.Errorf("port %d of container %s is not published to the host", port, containerID)
+}
+
+// Header returns the column names of the scraped metrics
+func (r *runtimeScraper) Header() string {
+	columns := make([]string, len(r.metrics))
+	for i, metric := range r.metrics {
+		columns[i] = columnName(metric)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// columnName keeps the labels of a metric from splitting its column of the dataset, the labels
+// of http_requests_total{code="200",method="get"} are written as {code=200;method=get}
+func columnName(metric string) string {
+	return strings.NewReplacer(",", ";", `"`, "").Replace(metric)
+}
+
+// Scrape reads the current value of each metric. Metrics that could not be read are nil.
+func (r *runtimeScraper) Scrape() []*float64 {
+	values := make([]*float64, len(r.metrics))
+
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		log.Debug().Msgf("unable to scrape runtime metrics: %v", err)
+		return values
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Debug().Msgf("unable to scrape runtime metrics: %s returned %s", r.url, resp.Status)
+		return values
+	}
+
+	if r.format == expvarFormat {
+		err = parseExpvar(resp.Body, r.metrics, values)
+	} else {
+		err = parsePrometheus(resp.Body, r.metrics, values)
+	}
+	if err != nil {
+		log.Debug().Msgf("unable to parse runtime metrics: %v", err)
+	}
+
+	return values
+}
+
+// parsePrometheus reads metrics in the prometheus text exposition format. A metric given
+// with labels matches that series exactly, otherwise every series of the metric is summed.
+func parsePrometheus(r io.Reader, metrics []string, values []*float64) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		series, rest := splitPrometheusSeries(line)
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for series %s: %v", series, err)
+		}
+
+		name, _, _ := strings.Cut(series, "{")
+		for i, metric := range metrics {
+			if metric != series && metric != name {
+				continue
+			}
+
+			if values[i] == nil {
+				values[i] = new(float64)
+			}
+			*values[i] += value
+		}
+	}
+	return scanner.Err()
+}
+
+// splitPrometheusSeries separates the series of a sample line from its value
+func splitPrometheusSeries(line string) (string, string) {
+	end := strings.IndexAny(line, "{ \t")
+	if end == -1 {
+		return line, ""
+	}
+
+	if line[end] == '{' {
+		closing := strings.LastIndex(line, "}")
+		if closing > end {
+			end = closing + 1
+		}
+	}
+	return line[:end], line[end:]
+}
+
+// parseExpvar reads metrics from the json document served by expvar. Nested values are
+// selected with a dotted path, like memstats.HeapAlloc.
+func parseExpvar(r io.Reader, metrics []string, values []*float64) error {
+	vars := map[string]interface{}{}
+	err := json.NewDecoder(r).Decode(&vars)
+	if err != nil {
+		return err
+	}
+
+	for i, metric := range metrics {
+		var current interface{} = vars
+		for _, key := range strings.Split(metric, ".") {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				current = nil
+				break
+			}
+			current = object[key]
+		}
+
+		switch v := current.(type) {
+		case float64:
+			values[i] = &v
+		case bool:
+			value := 0.0
+			if v {
+				value = 1
+			}
+			values[i] = &value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePrometheus(t *testing.T) {
+	exposition := `# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 12
+go_memstats_heap_alloc_bytes 1.048576e+06
+http_requests_total{code="200",method="get"} 30
+http_requests_total{code="500",method="get"} 2
+`
+	metrics := []string{
+		"go_goroutines",
+		"go_memstats_heap_alloc_bytes",
+		"http_requests_total",
+		`http_requests_total{code="500",method="get"}`,
+		"jvm_memory_used_bytes",
+	}
+	values := make([]*float64, len(metrics))
+	err := parsePrometheus(strings.NewReader(exposition), metrics, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []float64{12, 1048576, 32, 2}
+	for i, v := range expect {
+		if values[i] == nil || *values[i] != v {
+			t.Errorf("Incorrect value for metric %s: expected %v, got %v", metrics[i], v, values[i])
+		}
+	}
+	if values[4] != nil {
+		t.Errorf("Expected missing metric %s to be nil, got %v", metrics[4], *values[4])
+	}
+}
+
+func TestRuntimeHeader(t *testing.T) {
+	scraper := &runtimeScraper{metrics: []string{"go_goroutines", `http_requests_total{code="200",method="get"}`}}
+	header := scraper.Header()
+	if header != "go_goroutines, http_requests_total{code=200;method=get}" {
+		t.Errorf("expected the labels of a metric to stay in one column, got %s", header)
+	}
+	if columns := strings.Split(header, ","); len(columns) != 2 {
+		t.Errorf("expected 2 columns, got %d", len(columns))
+	}
+}
+
+func TestParseExpvar(t *testing.T) {
+	vars := `{"cmdline": ["./app"], "memstats": {"HeapAlloc": 2048, "NumGC": 3, "EnableGC": true}}`
+	metrics := []string{"memstats.HeapAlloc", "memstats.NumGC", "memstats.EnableGC", "cmdline.0", "memstats.Missing"}
+	values := make([]*float64, len(metrics))
+	err := parseExpvar(strings.NewReader(vars), metrics, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []float64{2048, 3, 1}
+	for i, v := range expect {
+		if values[i] == nil || *values[i] != v {
+			t.Errorf("Incorrect value for metric %s: expected %v, got %v", metrics[i], v, values[i])
+		}
+	}
+	for _, i := range []int{3, 4} {
+		if values[i] != nil {
+			t.Errorf("Expected metric %s to be nil, got %v", metrics[i], *values[i])
+		}
+	}
+}
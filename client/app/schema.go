@@ -0,0 +1,129 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+)
+
+const (
+	// SchemaFile is the default name of the json schema of config files
+	SchemaFile = "config.schema.json"
+
+	jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+	jobSchemaRef    = "#/$defs/job"
+)
+
+// jsonSchema is the subset of json schema that describes a config file
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+// ConfigSchema generates the json schema of config files from RunConfig. It describes a config as
+// it is written, so it also covers defaults, templates, extends and matrix.
+func ConfigSchema() ([]byte, error) {
+	root := schemaOf(reflect.TypeOf(RunConfig{}))
+	root.Schema = jsonSchemaDraft
+	root.Title = "agent-p config"
+	root.Required = []string{"jobs"}
+
+	job := schemaOf(reflect.TypeOf(Run{}))
+	job.Properties["extends"] = &jsonSchema{
+		AnyOf: []*jsonSchema{
+			{Type: "string"},
+			{Type: "array", Items: &jsonSchema{Type: "string"}},
+		},
+	}
+	scalar := &jsonSchema{AnyOf: []*jsonSchema{{Type: "string"}, {Type: "number"}, {Type: "boolean"}}}
+	job.Properties["matrix"] = &jsonSchema{
+		Type: "object",
+		AdditionalProperties: &jsonSchema{
+			AnyOf: []*jsonSchema{scalar, {Type: "array", Items: scalar}},
+		},
+	}
+
+	root.Defs = map[string]*jsonSchema{"job": job}
+	root.Properties["jobs"] = &jsonSchema{
+		Type:  "array",
+		Items: &jsonSchema{Ref: jobSchemaRef, Required: []string{"name"}},
+	}
+	root.Properties[defaultsKey] = &jsonSchema{Ref: jobSchemaRef}
+	root.Properties[templatesKey] = &jsonSchema{
+		Type:                 "object",
+		AdditionalProperties: &jsonSchema{Ref: jobSchemaRef},
+	}
+
+	schema, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(schema, '\n'), nil
+}
+
+// CreateSchema writes the json schema of config files to a file
+func CreateSchema(file string) error {
+	schema, err := ConfigSchema()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, schema, 0644)
+}
+
+func schemaOf(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		schema := &jsonSchema{
+			Type:                 "object",
+			Properties:           map[string]*jsonSchema{},
+			AdditionalProperties: false,
+		}
+		for name, field := range yamlFields(t) {
+			property := schemaOf(field.Type)
+			if enum := field.Tag.Get("enum"); enum != "" {
+				property.Enum = strings.Split(enum, ",")
+			}
+			schema.Properties[name] = property
+		}
+		return schema
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0
+		return interpolated(&jsonSchema{Type: "integer", Minimum: &zero})
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return interpolated(&jsonSchema{Type: "integer"})
+	case reflect.Bool:
+		return interpolated(&jsonSchema{Type: "boolean"})
+	}
+	return &jsonSchema{Type: "string"}
+}
+
+// interpolated lets a value that is not a string also be written as an environment variable
+// reference or a template
+func interpolated(schema *jsonSchema) *jsonSchema {
+	return &jsonSchema{
+		AnyOf: []*jsonSchema{
+			schema,
+			{Type: "string", Pattern: `\$\{|\{\{`},
+		},
+	}
+}
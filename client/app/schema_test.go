@@ -0,0 +1,54 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// the published schema lives at the root of the repository
+const publishedSchema = "../../" + SchemaFile
+
+func TestPublishedSchemaIsCurrent(t *testing.T) {
+	published, err := os.ReadFile(publishedSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := ConfigSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(published) != string(schema) {
+		t.Errorf("%s is out of date, regenerate it with `agent-p create schema %s`", SchemaFile, publishedSchema)
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema, err := ConfigSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := map[string]interface{}{}
+	err = json.Unmarshal(schema, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := decoded["$defs"].(map[string]interface{})["job"].(map[string]interface{})
+	properties := job["properties"].(map[string]interface{})
+	for _, key := range []string{"name", "app", "data", "traffic-driver", "extends", "matrix"} {
+		if _, ok := properties[key]; !ok {
+			t.Errorf("job schema is missing %s", key)
+		}
+	}
+	if job["additionalProperties"] != false {
+		t.Error("job schema should not allow unknown keys")
+	}
+
+	traffic := properties["traffic-driver"].(map[string]interface{})["properties"].(map[string]interface{})["traffic"].(map[string]interface{})
+	if _, ok := traffic["properties"].(map[string]interface{})["concurrent-requests"]; !ok {
+		t.Error("traffic schema is missing concurrent-requests")
+	}
+}
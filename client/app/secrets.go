@@ -0,0 +1,158 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	licenseKeyVar = "NEW_RELIC_LICENSE_KEY"
+
+	// secretsFile holds the secret environment variables of a job's app, it is only readable by its
+	// owner. It is not named .env, which compose would also read to interpolate the compose file.
+	secretsFile = "secrets.env"
+	// legacySecretsFile is where older versions wrote the secrets of a job's app
+	legacySecretsFile = ".env"
+	filePrefix        = "file:"
+	redacted          = "[REDACTED]"
+	// minSecretLength is the length of the shortest secret that is redacted, a shorter value like 4
+	// or true would replace unrelated text in everything agent-p logs
+	minSecretLength = 8
+)
+
+var envReference = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// resolveSecret reads the value of a secret reference. A value of ${VAR} reads the environment
+// variable VAR, and a value of file:path reads the contents of a file. The second return value
+// is false when the value is not a reference, in which case it is returned as is.
+func resolveSecret(value string) (string, bool, error) {
+	trimmed := strings.TrimSpace(value)
+	if match := envReference.FindStringSubmatch(trimmed); match != nil {
+		secret, ok := os.LookupEnv(match[1])
+		if !ok || secret == "" {
+			return "", true, fmt.Errorf("environment variable %s is not set", match[1])
+		}
+		secrets.add(secret)
+		return secret, true, nil
+	}
+	return resolveSecretFile(value)
+}
+
+// resolveSecretFile reads the contents of a file:path reference. The second return value is
+// false when the value is not a reference, in which case it is returned as is.
+func resolveSecretFile(value string) (string, bool, error) {
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, filePrefix) {
+		file := strings.TrimSpace(strings.TrimPrefix(trimmed, filePrefix))
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", true, err
+		}
+		secret := strings.TrimSpace(string(content))
+		if secret == "" {
+			return "", true, fmt.Errorf("secret file %s is empty", file)
+		}
+		secrets.add(secret)
+		return secret, true, nil
+	}
+
+	return value, false, nil
+}
+
+// splitSecrets separates the variables in a list of KEY=VALUE pairs that must not be written to
+// a compose file
+func splitSecrets(vars []string, secretKeys map[string]bool) (env []string, secret []string) {
+	env = []string{}
+	for _, v := range vars {
+		key, _, _ := strings.Cut(v, "=")
+		if secretKeys[key] {
+			secret = append(secret, v)
+		} else {
+			env = append(env, v)
+		}
+	}
+	return env, secret
+}
+
+// writeSecretsFile writes an env_file that only the owner can read
+func writeSecretsFile(file string, vars []string) error {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// the file may have been created with looser permissions by an older version
+	err = f.Chmod(0600)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range vars {
+		_, err = fmt.Fprintln(f, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secrets are the values that are redacted from everything agent-p logs
+var secrets = &secretRegistry{}
+
+type secretRegistry struct {
+	mu       sync.RWMutex
+	values   []string
+	replacer *strings.Replacer
+}
+
+func (s *secretRegistry) add(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.values {
+		if v == secret {
+			return
+		}
+	}
+
+	s.values = append(s.values, secret)
+	pairs := make([]string, 0, 2*len(s.values))
+	for _, v := range s.values {
+		pairs = append(pairs, v, redacted)
+	}
+	s.replacer = strings.NewReplacer(pairs...)
+}
+
+func (s *secretRegistry) redact(text string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.replacer == nil {
+		return text
+	}
+	return s.replacer.Replace(text)
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+// Redact wraps a writer so that secrets, like license keys, are never written to it
+func Redact(w io.Writer) io.Writer {
+	return &redactingWriter{w: w}
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	_, err := io.WriteString(r.w, secrets.redact(string(p)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
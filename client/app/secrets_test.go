@@ -0,0 +1,184 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("AGENT_P_TEST_SECRET", "from-env")
+	file := filepath.Join(t.TempDir(), "key")
+	err := os.WriteFile(file, []byte("from-file\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		value    string
+		expect   string
+		isSecret bool
+		isErr    bool
+	}{
+		{"plain", "plain", false, false},
+		{"${AGENT_P_TEST_SECRET}", "from-env", true, false},
+		{"file:" + file, "from-file", true, false},
+		{"${AGENT_P_TEST_UNSET}", "", true, true},
+		{"file:" + file + ".missing", "", true, true},
+		{"prefix ${AGENT_P_TEST_SECRET}", "prefix ${AGENT_P_TEST_SECRET}", false, false},
+	}
+
+	for _, c := range cases {
+		value, isSecret, err := resolveSecret(c.value)
+		if (err != nil) != c.isErr {
+			t.Errorf("resolveSecret(%s) error: %v", c.value, err)
+		}
+		if value != c.expect || isSecret != c.isSecret {
+			t.Errorf("resolveSecret(%s) = %s, %v; expected %s, %v", c.value, value, isSecret, c.expect, c.isSecret)
+		}
+	}
+}
+
+func TestSplitSecrets(t *testing.T) {
+	env, secret := splitSecrets([]string{
+		"NEW_RELIC_LICENSE_KEY=abc",
+		"NEW_RELIC_APP_NAME=job",
+		"TOKEN=a=b",
+	}, map[string]bool{licenseKeyVar: true, "TOKEN": true})
+
+	if !reflect.DeepEqual(env, []string{"NEW_RELIC_APP_NAME=job"}) {
+		t.Errorf("unexpected env: %v", env)
+	}
+	if !reflect.DeepEqual(secret, []string{"NEW_RELIC_LICENSE_KEY=abc", "TOKEN=a=b"}) {
+		t.Errorf("unexpected secrets: %v", secret)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	secrets.add("super-secret-key")
+	out := &bytes.Buffer{}
+	w := Redact(out)
+
+	msg := "license key super-secret-key is set"
+	n, err := w.Write([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(msg) {
+		t.Errorf("expected %d bytes written, got %d", len(msg), n)
+	}
+	if out.String() != "license key [REDACTED] is set" {
+		t.Errorf("secret was not redacted: %s", out.String())
+	}
+}
+
+func TestWriteSecretsFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), secretsFile)
+	err := os.WriteFile(file, []byte("OLD=1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writeSecretsFile(file, []string{"A=1", "B=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+	content, _ := os.ReadFile(file)
+	if string(content) != "A=1\nB=2\n" {
+		t.Errorf("unexpected content: %q", content)
+	}
+}
+
+func TestWriteWorkspaceIgnore(t *testing.T) {
+	workspace := t.TempDir() + "/"
+	err := os.WriteFile(workspace+".gitignore", []byte("*.log"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// entries that are already ignored are not added again
+	for i := 0; i < 2; i++ {
+		err = writeWorkspaceIgnore(workspace)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, _ := os.ReadFile(workspace + ".gitignore")
+	expected := "*.log\nsecrets.env\n" + collectorCertDir + "/" + collectorKeyFile + "\n"
+	if string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+}
+
+func TestShortValuesAreNotRedacted(t *testing.T) {
+	t.Setenv("AGENT_P_TEST_WORKERS", "4")
+	config := `version: 0.2.0
+new-relic-server: production
+new-relic-license-key: short-values-license-key
+jobs:
+  - name: web
+    app:
+      image: web:v4
+      service-port: 8040
+      environment-variables:
+        WORKERS: ${AGENT_P_TEST_WORKERS}
+        DEBUG: "true"
+`
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(config), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, problems, err := readConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := problems.errors(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	run := cfg.Runs[0]
+	if run.App.EnvVars["WORKERS"] != "4" || run.App.secretVars["WORKERS"] {
+		t.Errorf("expected WORKERS to be interpolated and not be a secret, got %s", run.App.EnvVars["WORKERS"])
+	}
+	_, compose, err := run.toJob(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(compose.secrets, []string{licenseKeyVar + "=short-values-license-key"}) {
+		t.Errorf("expected only the license key to be a secret, got %v", compose.secrets)
+	}
+
+	// a short value is not redacted even when it is read from a secret file
+	secrets.add("true")
+	out := &bytes.Buffer{}
+	msg := "web:v4 is listening on port 8040 with 4 workers, debug is true"
+	_, err = Redact(out).Write([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != msg {
+		t.Errorf("expected the log to be unchanged, got %s", out.String())
+	}
+
+	manifest, err := redactedConfig(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := manifest["app"].(map[string]interface{})
+	env := app["environment-variables"].(map[string]interface{})
+	if app["image"] != "web:v4" || env["WORKERS"] != "4" || env["DEBUG"] != "true" {
+		t.Errorf("expected the manifest config to be unchanged, got %v", app)
+	}
+}
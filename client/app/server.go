@@ -0,0 +1,177 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	customServer = "custom"
+
+	// trustedCertDir is the directory of the app container that certificates are mounted in
+	trustedCertDir = "/etc/agent-p/certs"
+	// caBundlePath is where the ca-bundle of a custom server is mounted in the app container
+	caBundlePath = trustedCertDir + "/ca-bundle.pem"
+)
+
+// systemCertDirs are where the images of common distributions keep their trusted certificates
+var systemCertDirs = []string{"/etc/ssl/certs", "/etc/pki/tls/certs"}
+
+// serverEndpoint is where agents send each kind of data for a New Relic server. Empty hosts are
+// left to the agent's defaults.
+type serverEndpoint struct {
+	Host         string `yaml:"host,omitempty"`
+	Port         uint   `yaml:"port,omitempty"`
+	TLS          bool   `yaml:"tls"`
+	InsightsHost string `yaml:"insights-host,omitempty"`
+	MetricsHost  string `yaml:"metrics-host,omitempty"`
+}
+
+var serverEndpoints = map[string]serverEndpoint{
+	"production": {
+		TLS: true,
+	},
+	"staging": {
+		Host:         "staging-collector.newrelic.com",
+		TLS:          true,
+		InsightsHost: "staging-insights-collector.newrelic.com",
+		MetricsHost:  "staging-metric-api.newrelic.com",
+	},
+	"eu": {
+		Host:         "collector.eu01.nr-data.net",
+		TLS:          true,
+		InsightsHost: "insights-collector.eu01.nr-data.net",
+		MetricsHost:  "metric-api.eu.newrelic.com",
+	},
+	localServer: {
+		Host: collectorName,
+		TLS:  true,
+	},
+}
+
+// CustomServer is a collector host, like a proxy, that agents send data to instead of New Relic
+type CustomServer struct {
+	Host         string `yaml:"host"`
+	Port         *uint  `yaml:"port,omitempty"`          // defaults to 443, or 80 without tls
+	TLS          *bool  `yaml:"tls,omitempty"`           // defaults to true
+	CABundle     string `yaml:"ca-bundle,omitempty"`     // pem file of certificates the app should trust
+	InsightsHost string `yaml:"insights-host,omitempty"` // defaults to host
+	MetricsHost  string `yaml:"metrics-host,omitempty"`  // defaults to host
+}
+
+var (
+	errCustomServerEmpty  = errors.New("config error: custom-server.host must be set when new-relic-server is custom")
+	errCustomServerUnused = errors.New("config error: custom-server can only be set when new-relic-server is custom")
+	errCustomServerPort   = errors.New("config error: custom-server.port must be between 1 and 65535")
+)
+
+func (c *CustomServer) defaultAndValidate() []error {
+	errs := []error{}
+	c.Host = strings.TrimSpace(c.Host)
+	if c.Host == "" {
+		errs = append(errs, fieldError{"custom-server", errCustomServerEmpty})
+	}
+	if strings.ContainsAny(c.Host, "/:") {
+		errs = append(errs, fieldError{"custom-server.host", fmt.Errorf("config error: custom-server.host must be a host name without a scheme, port, or path, got \"%s\"", c.Host)})
+	}
+
+	if c.TLS == nil {
+		tls := true
+		c.TLS = &tls
+	}
+	if c.Port == nil {
+		if *c.TLS {
+			c.Port = UintPointer(443)
+		} else {
+			c.Port = UintPointer(80)
+		}
+	}
+	if *c.Port == 0 || *c.Port > 65535 {
+		errs = append(errs, fieldError{"custom-server.port", errCustomServerPort})
+	}
+
+	if c.InsightsHost == "" {
+		c.InsightsHost = c.Host
+	}
+	if c.MetricsHost == "" {
+		c.MetricsHost = c.Host
+	}
+
+	if c.CABundle != "" {
+		// the bundle is mounted from the compose file of each job, which lives in another directory
+		path, err := filepath.Abs(c.CABundle)
+		if err != nil {
+			return append(errs, err)
+		}
+		_, err = os.Stat(path)
+		if err != nil {
+			errs = append(errs, fieldError{"custom-server.ca-bundle", fmt.Errorf("config error: custom-server.ca-bundle %s can not be read: %v", c.CABundle, err)})
+		} else {
+			c.CABundle = path
+		}
+	}
+	return errs
+}
+
+func (c *CustomServer) endpoint() serverEndpoint {
+	return serverEndpoint{
+		Host:         c.Host,
+		Port:         *c.Port,
+		TLS:          *c.TLS,
+		InsightsHost: c.InsightsHost,
+		MetricsHost:  c.MetricsHost,
+	}
+}
+
+// env returns the variables that point an agent at each host of the endpoint
+func (e serverEndpoint) env() []string {
+	vars := []string{}
+	if e.Host != "" {
+		vars = append(vars, fmt.Sprintf("%s=%s", "NEW_RELIC_HOST", e.Host))
+	}
+	if e.Port != 0 {
+		vars = append(vars, fmt.Sprintf("%s=%d", "NEW_RELIC_PORT", e.Port))
+	}
+	if !e.TLS {
+		vars = append(vars, fmt.Sprintf("%s=%s", "NEW_RELIC_SSL", "false"))
+	}
+
+	scheme := "https"
+	if !e.TLS {
+		scheme = "http"
+	}
+	if e.InsightsHost != "" {
+		vars = append(vars, fmt.Sprintf("%s=%s", "NEW_RELIC_INSIGHTS_HOST", e.InsightsHost))
+		vars = append(vars, fmt.Sprintf("%s=%s://%s/v1/accounts/events", "NEW_RELIC_EVENT_INGEST_URI", scheme, e.hostPort(e.InsightsHost)))
+	}
+	if e.MetricsHost != "" {
+		vars = append(vars, fmt.Sprintf("%s=%s", "NEW_RELIC_METRICS_HOST", e.MetricsHost))
+		vars = append(vars, fmt.Sprintf("%s=%s://%s/metric/v1", "NEW_RELIC_METRIC_INGEST_URI", scheme, e.hostPort(e.MetricsHost)))
+	}
+	return vars
+}
+
+func (e serverEndpoint) hostPort(host string) string {
+	if e.Port == 0 {
+		return host
+	}
+	return fmt.Sprintf("%s:%d", host, e.Port)
+}
+
+// trustCertificate mounts a pem file into the app container, and points the agents at it. The
+// certificate is trusted along with the ones of the container, not instead of them, so the app
+// can still make other https calls: go reads SSL_CERT_DIR on top of the system bundle, and node
+// adds NODE_EXTRA_CA_CERTS to its own.
+func trustCertificate(app *Service, hostPath, containerPath string) {
+	certDirs := append([]string{path.Dir(containerPath)}, systemCertDirs...)
+	app.Volumes = append(app.Volumes, fmt.Sprintf("%s:%s:ro", hostPath, containerPath))
+	app.Environment = append(app.Environment,
+		fmt.Sprintf("%s=%s", "NEW_RELIC_CA_BUNDLE_PATH", containerPath),
+		fmt.Sprintf("%s=%s", "SSL_CERT_DIR", strings.Join(certDirs, ":")),
+		fmt.Sprintf("%s=%s", "NODE_EXTRA_CA_CERTS", containerPath),
+	)
+}
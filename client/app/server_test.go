@@ -0,0 +1,106 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServerEndpointEnv(t *testing.T) {
+	cfg := RunConfig{
+		Server:     "EU",
+		LicenseKey: "key",
+		Runs: []Run{{
+			Name: "eu",
+			App:  App{Image: "app", Port: UintPointer(8000)},
+		}},
+	}
+	errs := cfg.defaultAndValidate()
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	env := strings.Join(cfg.Runs[0].appEnv(&cfg), "\n")
+	for _, expect := range []string{
+		"NEW_RELIC_HOST=collector.eu01.nr-data.net",
+		"NEW_RELIC_INSIGHTS_HOST=insights-collector.eu01.nr-data.net",
+		"NEW_RELIC_METRIC_INGEST_URI=https://metric-api.eu.newrelic.com/metric/v1",
+	} {
+		if !strings.Contains(env, expect) {
+			t.Errorf("Expected eu jobs to have %s, got:\n%s", expect, env)
+		}
+	}
+}
+
+func TestCustomServer(t *testing.T) {
+	tls := false
+	tests := []struct {
+		server  CustomServer
+		invalid bool
+		expect  []string
+	}{
+		{
+			server: CustomServer{Host: "proxy.internal"},
+			expect: []string{"NEW_RELIC_HOST=proxy.internal", "NEW_RELIC_PORT=443", "NEW_RELIC_EVENT_INGEST_URI=https://proxy.internal:443/v1/accounts/events"},
+		},
+		{
+			server: CustomServer{Host: "proxy.internal", Port: UintPointer(8080), TLS: &tls, MetricsHost: "metrics.internal"},
+			expect: []string{"NEW_RELIC_PORT=8080", "NEW_RELIC_SSL=false", "NEW_RELIC_METRIC_INGEST_URI=http://metrics.internal:8080/metric/v1"},
+		},
+		{
+			server:  CustomServer{Host: "https://proxy.internal"},
+			invalid: true,
+		},
+		{
+			server:  CustomServer{Host: "proxy.internal", Port: UintPointer(70000)},
+			invalid: true,
+		},
+		{
+			server:  CustomServer{},
+			invalid: true,
+		},
+	}
+
+	for _, test := range tests {
+		errs := test.server.defaultAndValidate()
+		if test.invalid {
+			if len(errs) == 0 {
+				t.Errorf("Expected custom server %+v to be invalid", test.server)
+			}
+			continue
+		}
+		if len(errs) > 0 {
+			t.Error(errs)
+			continue
+		}
+
+		env := strings.Join(test.server.endpoint().env(), "\n")
+		for _, expect := range test.expect {
+			if !strings.Contains(env, expect) {
+				t.Errorf("Expected custom server to have %s, got:\n%s", expect, env)
+			}
+		}
+	}
+}
+
+func TestTrustCertificate(t *testing.T) {
+	app := Service{Image: "app", Environment: []string{"A=1"}}
+	trustCertificate(&app, "./proxy-ca.pem", caBundlePath)
+
+	if len(app.Volumes) != 1 || app.Volumes[0] != "./proxy-ca.pem:/etc/agent-p/certs/ca-bundle.pem:ro" {
+		t.Errorf("expected the certificate to be mounted, got %v", app.Volumes)
+	}
+	env := strings.Join(app.Environment, "\n")
+	for _, expect := range []string{
+		"NEW_RELIC_CA_BUNDLE_PATH=/etc/agent-p/certs/ca-bundle.pem",
+		"SSL_CERT_DIR=/etc/agent-p/certs:/etc/ssl/certs:/etc/pki/tls/certs",
+		"NODE_EXTRA_CA_CERTS=/etc/agent-p/certs/ca-bundle.pem",
+	} {
+		if !strings.Contains(env, expect) {
+			t.Errorf("Expected the app to have %s, got:\n%s", expect, env)
+		}
+	}
+	// SSL_CERT_FILE would replace every certificate the container trusts
+	if strings.Contains(env, "SSL_CERT_FILE") {
+		t.Errorf("Expected the certificates of the container to still be trusted, got:\n%s", env)
+	}
+}
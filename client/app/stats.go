@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxStatsRetries is the number of consecutive failures to read from the stats
+	// stream that are tolerated before giving up on a container
+	maxStatsRetries = 5
+	statsRetryDelay = 500 * time.Millisecond
+)
+
+// statSample is a single reading of a container's resource usage, stamped with the
+// time the docker daemon read it.
+type statSample struct {
+	Read  time.Time
+	Stats types.StatsJSON
+}
+
+// statsSource provides the most recent resource usage sample of a container
+type statsSource interface {
+	Latest() (statSample, bool)
+	// Err is set once the source has given up reading from the container
+	Err() error
+	Close()
+}
+
+// statsStream holds a long lived subscription to the docker stats stream of a
+// container and keeps the most recent sample it has received.
+type statsStream struct {
+	mu     sync.Mutex
+	latest *statSample
+	err    error
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// streamStats subscribes to the stats of a container until Close is called
+func streamStats(cli *client.Client, containerID string) *statsStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &statsStream{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go s.run(ctx, cli, containerID)
+	return s
+}
+
+// Latest returns the most recent sample read from the stream, and false if no sample
+// has been read yet.
+func (s *statsStream) Latest() (statSample, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest == nil {
+		return statSample{}, false
+	}
+	return *s.latest, true
+}
+
+func (s *statsStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription and waits for the stream to shut down
+func (s *statsStream) Close() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *statsStream) run(ctx context.Context, cli *client.Client, containerID string) {
+	defer close(s.done)
+
+	failures := 0
+	for {
+		err := s.read(ctx, cli, containerID, &failures)
+		if ctx.Err() != nil {
+			return
+		}
+
+		failures++
+		if failures > maxStatsRetries {
+			s.mu.Lock()
+			s.err = fmt.Errorf("unable to read stats for container %s after %d attempts: %v", containerID, maxStatsRetries, err)
+			s.mu.Unlock()
+			return
+		}
+
+		log.Debug().Msgf("error reading stats for container %s, retrying (%d/%d): %v", containerID, failures, maxStatsRetries, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(failures) * statsRetryDelay):
+		}
+	}
+}
+
+// read consumes samples from a single stats subscription until it fails or the context is cancelled
+func (s *statsStream) read(ctx context.Context, cli *client.Client, containerID string, failures *int) error {
+	stats, err := cli.ContainerStats(ctx, containerID, true)
+	if err != nil {
+		return err
+	}
+	defer stats.Body.Close()
+
+	decoder := json.NewDecoder(stats.Body)
+	for {
+		sample := statSample{}
+		err := decoder.Decode(&sample.Stats)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("stats stream closed by docker")
+			}
+			return err
+		}
+
+		sample.Read = sample.Stats.Read
+		if sample.Read.IsZero() {
+			sample.Read = time.Now()
+		}
+
+		s.mu.Lock()
+		s.latest = &sample
+		s.mu.Unlock()
+		*failures = 0
+	}
+}
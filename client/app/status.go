@@ -0,0 +1,95 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// jobStatus is written to the directory of a run once the job stops running, so a later run can
+// tell whether the job already has complete results
+type jobStatus struct {
+	Job      string    `json:"job"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Compose is a checksum of the docker compose file the job ran, a job whose compose file
+	// has changed since is not complete
+	Compose string `json:"compose"`
+}
+
+// writeJobStatus records the outcome of a job in the directory of its run
+func writeJobStatus(job *Job, result jobResult, started time.Time) error {
+	checksum, err := composeChecksum(job.Directory)
+	if err != nil {
+		return err
+	}
+
+	status := jobStatus{
+		Job:      result.Name,
+		Status:   result.Status,
+		Started:  started,
+		Finished: started.Add(result.Duration),
+		Compose:  checksum,
+	}
+	if result.Err != nil {
+		status.Error = result.Err.Error()
+	}
+
+	content, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(job.Output.GetStatusFile(), content, 0644)
+}
+
+// readJobStatus returns the recorded outcome of a run, or nil when it has none
+func readJobStatus(dir JobDirectory) (*jobStatus, error) {
+	content, err := os.ReadFile(dir.GetStatusFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status := &jobStatus{}
+	err = json.Unmarshal(content, status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// completedStatus returns the status of the latest run of a job when it succeeded with the
+// compose file the job has now, and nil when the job needs to run. A run that died before it
+// wrote a status is not complete.
+func completedStatus(dir JobDirectory) (*jobStatus, error) {
+	status, err := readJobStatus(dir.GetLatest())
+	if err != nil || status == nil || status.Status != succeededStatus {
+		return nil, err
+	}
+
+	checksum, err := composeChecksum(dir)
+	if err != nil {
+		return nil, err
+	}
+	if status.Compose != checksum {
+		return nil, nil
+	}
+	return status, nil
+}
+
+func composeChecksum(dir JobDirectory) (string, error) {
+	content, err := os.ReadFile(dir.GetCompose())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCompletedStatus(t *testing.T) {
+	dir := JobDirectory(t.TempDir() + "/")
+	err := os.WriteFile(dir.GetCompose(), []byte("services: {}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := completedStatus(dir)
+	if err != nil || status != nil {
+		t.Fatalf("expected a job that never ran to need a run, got %v, %v", status, err)
+	}
+
+	job := &Job{Name: "web", Directory: dir}
+	job.Output, err = dir.createRun("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = completedStatus(dir)
+	if err != nil || status != nil {
+		t.Fatalf("expected a job without a status to need a run, got %v, %v", status, err)
+	}
+
+	started := time.Now()
+	err = writeJobStatus(job, newJobResult("web", errors.New("exit status 1"), time.Minute), started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = completedStatus(dir)
+	if err != nil || status != nil {
+		t.Fatalf("expected a failed job to need a run, got %v, %v", status, err)
+	}
+
+	err = writeJobStatus(job, newJobResult("web", nil, time.Minute), started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = completedStatus(dir)
+	if err != nil || status == nil {
+		t.Fatalf("expected a succeeded job to be complete, got %v, %v", status, err)
+	}
+	if status.Finished.Sub(status.Started) != time.Minute {
+		t.Errorf("expected the job to have run for a minute, got %s", status.Finished.Sub(status.Started))
+	}
+
+	err = os.WriteFile(dir.GetCompose(), []byte("services: {app: {}}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = completedStatus(dir)
+	if err != nil || status != nil {
+		t.Errorf("expected a job with a changed compose file to need a run, got %v, %v", status, err)
+	}
+}
+
+func TestCreateRun(t *testing.T) {
+	dir := JobDirectory(t.TempDir() + "/")
+
+	first, err := dir.createRun("20261019T120000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := dir.createRun("20261019T120000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || second != dir.GetRun("20261019T120000Z-2") {
+		t.Fatalf("expected a second run with the same id to get a suffix, got %s and %s", first, second)
+	}
+
+	err = os.WriteFile(second.GetDataFile(), []byte("data"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(dir.GetLatest().GetDataFile())
+	if err != nil || string(content) != "data" {
+		t.Errorf("expected latest to point at the second run, got %q, %v", content, err)
+	}
+}
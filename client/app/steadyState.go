@@ -0,0 +1,194 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Phases of the samples of a job relative to the steady state of the app, only samples in the
+// steady state are summarized
+const (
+	warmupPhase   = "warmup"
+	steadyPhase   = "steady"
+	cooldownPhase = "cooldown"
+)
+
+const (
+	phaseColumn = "Phase"
+	// steadyStateWindow is how long the samples of an app have to stay stable for it to be settled
+	steadyStateWindow = 10 * time.Second
+	// steadyStateMinSamples keeps a short window from mistaking a brief plateau for a steady state
+	steadyStateMinSamples = 5
+	// steadyStateMaxCV is the coefficient of variation of the cpu of an app in a steady state
+	steadyStateMaxCV = 0.1
+)
+
+// samplePhase is the phase of a sample read at elapsed since monitoring started. Samples from
+// before traffic starts are part of the warmup, and samples from after it stops the cooldown.
+func (j *Job) samplePhase(elapsed time.Duration) string {
+	switch {
+	case elapsed < j.LoadDelay+j.Warmup:
+		return warmupPhase
+	case elapsed >= j.LoadDelay+j.LoadDuration-j.Cooldown:
+		return cooldownPhase
+	default:
+		return steadyPhase
+	}
+}
+
+// detectWarmup finds the first sample of the steady state, where the cv of a window of samples
+// first stays under maxCV. It returns -1 when the values never settle.
+func detectWarmup(values []float64, window int, maxCV float64) int {
+	for start := 0; start+window <= len(values); start++ {
+		if coefficientOfVariation(values[start:start+window]) <= maxCV {
+			return start
+		}
+	}
+	return -1
+}
+
+// coefficientOfVariation is the standard deviation of values relative to their mean, values that
+// are all zero do not vary
+func coefficientOfVariation(values []float64) float64 {
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(values)))
+	if mean == 0 {
+		if stddev == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return stddev / math.Abs(mean)
+}
+
+// tagWarmup detects the end of the warmup of a job from the cpu of the samples in its dataset,
+// and tags the samples before it as warmup. When the app never settles, the default warmup is used.
+func (j *Job) tagWarmup() error {
+	points, err := j.Output.ReadData()
+	if err != nil {
+		return err
+	}
+
+	steady := []int{}
+	cpu := []float64{}
+	for i, point := range points {
+		if point.Phase == steadyPhase {
+			steady = append(steady, i)
+			cpu = append(cpu, point.CPUPercent)
+		}
+	}
+
+	window := int(steadyStateWindow / j.DataCollectionInterval)
+	if window < steadyStateMinSamples {
+		window = steadyStateMinSamples
+	}
+
+	settled := detectWarmup(cpu, window, steadyStateMaxCV)
+	if settled < 0 {
+		log.Warn().Msgf("job %s did not settle into a steady state, assuming a warmup of %s", j.Name, defaultWarmup)
+		settled = 0
+		for settled < len(steady) && points[steady[settled]].Timestamp.Sub(points[0].Timestamp) < j.LoadDelay+defaultWarmup {
+			settled++
+		}
+	} else if settled < len(steady) {
+		log.Info().Msgf("job %s settled into a steady state %s after traffic started", j.Name,
+			points[steady[settled]].Timestamp.Sub(points[0].Timestamp)-j.LoadDelay)
+	}
+
+	phases := map[int64]string{}
+	for _, i := range steady[:settled] {
+		phases[points[i].Timestamp.UnixNano()] = warmupPhase
+	}
+	return rewritePhases(j.Output.GetDataFile(), phases)
+}
+
+// rewritePhases sets the phase of the samples of a dataset, keyed by the unix nano time they were
+// read at, keeping every other column. Samples are matched by their timestamp, so a row that is
+// not a sample does not shift the phases of the rows after it.
+func rewritePhases(file string, phases map[int64]string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var header []string
+	for i := 0; i < 2; i++ {
+		header, err = r.Read()
+		if err != nil {
+			return fmt.Errorf("%s has no header: %w", file, err)
+		}
+	}
+	column := columnIndex(strings.Join(header, ","), phaseColumn)
+	if column < 0 {
+		return fmt.Errorf("%s has no %s column", file, phaseColumn)
+	}
+	// the title and header are kept as they were written
+	head := content[:r.InputOffset()]
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	for _, record := range records {
+		timestamp, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(record[0]))
+		if err != nil || column >= len(record) {
+			continue
+		}
+		if phase, ok := phases[timestamp.UnixNano()]; ok {
+			record[column] = phase
+		}
+	}
+
+	out := bytes.NewBuffer(append([]byte{}, head...))
+	w := csv.NewWriter(out)
+	err = w.WriteAll(records)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, out.Bytes(), 0644)
+}
+
+// columnIndex is the index of a column in the header of a dataset, or -1 when it has none
+func columnIndex(header, name string) int {
+	for i, column := range strings.Split(header, ",") {
+		if strings.TrimSpace(column) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// steadyState is the samples of a dataset that are in the steady state. Datasets written before
+// samples were tagged are returned whole.
+func steadyState(points []dataPoint) []dataPoint {
+	steady := []dataPoint{}
+	for _, point := range points {
+		if point.Phase == "" {
+			return points
+		}
+		if point.Phase == steadyPhase {
+			steady = append(steady, point)
+		}
+	}
+	return steady
+}
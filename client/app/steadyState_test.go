@@ -0,0 +1,169 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplePhase(t *testing.T) {
+	job := &Job{LoadDelay: 2 * time.Second, LoadDuration: 30 * time.Second, Warmup: 5 * time.Second, Cooldown: 3 * time.Second}
+	tests := map[time.Duration]string{
+		time.Second:      warmupPhase,
+		6 * time.Second:  warmupPhase,
+		7 * time.Second:  steadyPhase,
+		28 * time.Second: steadyPhase,
+		29 * time.Second: cooldownPhase,
+		40 * time.Second: cooldownPhase,
+	}
+	for elapsed, expected := range tests {
+		if phase := job.samplePhase(elapsed); phase != expected {
+			t.Errorf("expected a sample read %s in to be in the %s phase, got %s", elapsed, expected, phase)
+		}
+	}
+}
+
+func TestDetectWarmup(t *testing.T) {
+	values := []float64{80, 5, 60, 20, 45, 30, 31, 29, 30, 30, 31, 30}
+	if start := detectWarmup(values, 5, 0.1); start != 5 {
+		t.Errorf("expected the steady state to start at sample 5, got %d", start)
+	}
+	if start := detectWarmup(values[:6], 5, 0.1); start != -1 {
+		t.Errorf("expected values that never settle to have no steady state, got %d", start)
+	}
+	if start := detectWarmup([]float64{0, 0, 0, 0, 0}, 5, 0.1); start != 0 {
+		t.Errorf("expected an idle app to be steady, got %d", start)
+	}
+}
+
+func TestTagWarmup(t *testing.T) {
+	dir := JobDirectory(t.TempDir() + "/")
+	job := &Job{Name: "web", Output: dir, LoadDuration: 20 * time.Second, Cooldown: 2 * time.Second, DataCollectionInterval: time.Second, AutoWarmup: true}
+
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	data := &strings.Builder{}
+	data.WriteString("Timeseries Data Measuring the Perfomance of Job web\n")
+	data.WriteString("Timestamp, Sample Interval ms, CPU utilization %, Memory Usage Mb, Disk Write Kb, Outbound Network Traffic Kb, Phase, go_goroutines\n")
+	cpu := []float64{90, 10, 70, 25, 50, 30, 30, 31, 29, 30, 30, 31, 30, 29, 30, 31, 30, 30, 5, 2}
+	for i, value := range cpu {
+		elapsed := time.Duration(i) * time.Second
+		fmt.Fprintf(data, "%s,1000.000,%.3f,64.000,0.000,1.000,%s,12\n", start.Add(elapsed).Format(time.RFC3339Nano), value, job.samplePhase(elapsed))
+	}
+	err := os.WriteFile(dir.GetDataFile(), []byte(data.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = job.tagWarmup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := dir.ReadData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, point := range points {
+		expected := steadyPhase
+		if i < 5 {
+			expected = warmupPhase
+		} else if i >= 18 {
+			expected = cooldownPhase
+		}
+		if point.Phase != expected {
+			t.Errorf("expected sample %d to be in the %s phase, got %s", i, expected, point.Phase)
+		}
+	}
+
+	// the runtime metrics after the phase are kept
+	content, err := os.ReadFile(dir.GetDataFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), ",warmup,12\n") {
+		t.Errorf("expected the runtime metrics of each sample to be kept, got:\n%s", content)
+	}
+
+	steady := steadyState(points)
+	if len(steady) != 13 {
+		t.Errorf("expected 13 samples in the steady state, got %d", len(steady))
+	}
+	summaries := summarizeSamples(points)
+	if summaries[0].Samples != 13 || summaries[0].Max != 31 {
+		t.Errorf("expected the cpu to be summarized from the steady state, got %+v", summaries[0])
+	}
+}
+
+func TestTimingWarmupAndCooldown(t *testing.T) {
+	run := Run{
+		Name:          "web",
+		Data:          Data{Interval: "1s", Warmup: "10s", Cooldown: "0s"},
+		TrafficDriver: TrafficDriver{Delay: "2s", Traffic: Traffic{Duration: "30s"}},
+	}
+	timing, err := run.timing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timing.warmup != 10*time.Second || timing.cooldown != 0 || timing.autoWarmup {
+		t.Errorf("expected a warmup of 10s and no cooldown, got %+v", timing)
+	}
+
+	run.Data.Warmup, run.Data.Cooldown = autoWarmup, ""
+	timing, err = run.timing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !timing.autoWarmup || timing.warmup != 0 || timing.cooldown != defaultCooldown {
+		t.Errorf("expected an automatic warmup and the default cooldown, got %+v", timing)
+	}
+
+	run.Data.Warmup, run.Data.Cooldown = "20s", "10s"
+	_, err = run.timing()
+	if err == nil || !strings.Contains(err.Error(), "no steady state") {
+		t.Errorf("expected an error when the warmup and cooldown cover the traffic, got %v", err)
+	}
+}
+
+func TestRewritePhases(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.csv")
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) string {
+		return start.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339Nano)
+	}
+	data := "Timeseries Data Measuring the Perfomance of Job web\n" +
+		"Timestamp, Sample Interval ms, CPU utilization %, Memory Usage Mb, Disk Write Kb, Outbound Network Traffic Kb, Phase, labels\n" +
+		at(0) + ",1000.000,90.000,64.000,0.000,1.000,steady,\"a,b\"\n" +
+		"partial row\n" +
+		at(1) + ",1000.000,10.000,64.000,0.000,1.000,steady,c\n" +
+		at(2) + ",1000.000,30.000,64.000,0.000,1.000,steady,d\n"
+	err := os.WriteFile(file, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = rewritePhases(file, map[int64]string{
+		start.UnixNano():                  warmupPhase,
+		start.Add(time.Second).UnixNano(): warmupPhase,
+		start.Add(time.Minute).UnixNano(): warmupPhase,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a quoted cell and a row that is not a sample do not shift the phases of the other rows
+	expected := "Timeseries Data Measuring the Perfomance of Job web\n" +
+		"Timestamp, Sample Interval ms, CPU utilization %, Memory Usage Mb, Disk Write Kb, Outbound Network Traffic Kb, Phase, labels\n" +
+		at(0) + ",1000.000,90.000,64.000,0.000,1.000,warmup,\"a,b\"\n" +
+		"partial row\n" +
+		at(1) + ",1000.000,10.000,64.000,0.000,1.000,warmup,c\n" +
+		at(2) + ",1000.000,30.000,64.000,0.000,1.000,steady,d\n"
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"agent-p/analysis"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runSummary is the robust statistics of the samples of a run, written to its summary.json.
+// Only samples in the steady state are analyzed, and outliers among them are flagged, not dropped.
+type runSummary struct {
+	Job      string                    `json:"job"`
+	Run      string                    `json:"run"`
+	Samples  int                       `json:"samples"`
+	Excluded int                       `json:"excluded"` // samples read during the warmup or cooldown
+	Metrics  map[string]metricAnalysis `json:"metrics"`
+}
+
+// metricAnalysis is the robust statistics of a metric, with the samples that are outliers
+type metricAnalysis struct {
+	analysis.Summary
+	OutlierSamples []outlierSample `json:"outlier-samples"`
+}
+
+type outlierSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// writeRunSummary analyzes the samples of the run of a job, a run that collected no data has no
+// summary
+func writeRunSummary(job *Job) error {
+	points, err := job.Output.ReadData()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	summary := analyzeSamples(job.Name, job.Output.runID(), points)
+	content, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	outliers := 0
+	for _, metric := range summary.Metrics {
+		outliers += len(metric.OutlierSamples)
+	}
+	if outliers > 0 {
+		log.Info().Msgf("flagged %d outliers in the samples of job %s, see %s", outliers, job.Name, job.Output.GetSummaryFile())
+	}
+	return os.WriteFile(job.Output.GetSummaryFile(), content, 0644)
+}
+
+func analyzeSamples(job, run string, points []dataPoint) runSummary {
+	steady := steadyState(points)
+	summary := runSummary{
+		Job:      job,
+		Run:      run,
+		Samples:  len(steady),
+		Excluded: len(points) - len(steady),
+		Metrics:  map[string]metricAnalysis{},
+	}
+	if len(steady) == 0 {
+		return summary
+	}
+
+	for _, metric := range sampleMetrics {
+		values := metric.values(steady)
+		result := metricAnalysis{Summary: analysis.Summarize(values), OutlierSamples: []outlierSample{}}
+		for _, i := range result.Summary.Outliers {
+			result.OutlierSamples = append(result.OutlierSamples, outlierSample{Timestamp: steady[i].Timestamp, Value: values[i]})
+		}
+		summary.Metrics[metric.name] = result
+	}
+	return summary
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteRunSummary(t *testing.T) {
+	dir := JobDirectory(t.TempDir() + "/runs/20261019T120000Z/")
+	err := os.MkdirAll(string(dir), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	data := &strings.Builder{}
+	data.WriteString("Timeseries Data Measuring the Perfomance of Job web\n")
+	data.WriteString("Timestamp, Sample Interval ms, CPU utilization %, Memory Usage Mb, Disk Write Kb, Outbound Network Traffic Kb, Phase\n")
+	cpu := []float64{90, 12, 10, 11, 13, 10, 12, 11, 95, 12, 11, 1}
+	for i, value := range cpu {
+		phase := steadyPhase
+		if i == 0 {
+			phase = warmupPhase
+		} else if i == len(cpu)-1 {
+			phase = cooldownPhase
+		}
+		fmt.Fprintf(data, "%s,1000.000,%.3f,64.000,0.000,1.000,%s\n", start.Add(time.Duration(i)*time.Second).Format(time.RFC3339Nano), value, phase)
+	}
+	err = os.WriteFile(dir.GetDataFile(), []byte(data.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writeRunSummary(&Job{Name: "web", Output: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(dir.GetSummaryFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary := runSummary{}
+	err = json.Unmarshal(content, &summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Job != "web" || summary.Run != "20261019T120000Z" || summary.Samples != 10 || summary.Excluded != 2 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	cpuSummary := summary.Metrics[cpuMetric]
+	if cpuSummary.Median != 11.5 || cpuSummary.Mean != 19.7 {
+		t.Errorf("expected the outlier to be kept in the mean but not move the median, got %+v", cpuSummary.Summary)
+	}
+	if len(cpuSummary.OutlierSamples) != 1 || cpuSummary.OutlierSamples[0].Value != 95 || !cpuSummary.OutlierSamples[0].Timestamp.Equal(start.Add(8*time.Second)) {
+		t.Errorf("expected the sample read at 12:00:08 to be flagged, got %+v", cpuSummary.OutlierSamples)
+	}
+	if memory := summary.Metrics[memoryMetric]; memory.MAD != 0 || len(memory.OutlierSamples) != 0 {
+		t.Errorf("expected a constant memory to have no spread or outliers, got %+v", memory)
+	}
+
+	// a run that collected no data has no summary
+	empty := JobDirectory(t.TempDir() + "/")
+	err = writeRunSummary(&Job{Name: "web", Output: empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(empty.GetSummaryFile()); !os.IsNotExist(err) {
+		t.Errorf("expected no summary without data, got %v", err)
+	}
+}
@@ -0,0 +1,342 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigProblem is a problem found in a config file, at a line and column when it is known
+type ConfigProblem struct {
+	Line    int
+	Column  int
+	Message string
+
+	// warning problems, like unknown keys, do not stop a config from being run
+	warning bool
+}
+
+func (p ConfigProblem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+	if p.Column == 0 {
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", p.Line, p.Column, p.Message)
+}
+
+// ConfigProblems are every problem found in a config file
+type ConfigProblems []ConfigProblem
+
+func (p ConfigProblems) Error() string {
+	messages := make([]string, len(p))
+	for i, problem := range p {
+		messages[i] = problem.String()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ValidateConfig reads a config file and returns every problem in it, including warnings
+func ValidateConfig(file string) (*RunConfig, ConfigProblems, error) {
+	return readConfig(file)
+}
+
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// readConfig reads a config file, fills in its defaults, and returns it with every problem found
+// in it. The error is only set when the file can not be read.
+func readConfig(file string) (*RunConfig, ConfigProblems, error) {
+	cfgBytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := yaml.Node{}
+	err = yaml.Unmarshal(cfgBytes, &root)
+	if err != nil {
+		return nil, ConfigProblems{lineProblem(&root, err.Error())}, nil
+	}
+	if root.Kind == 0 {
+		return nil, ConfigProblems{{Message: fmt.Sprintf("config file %s is empty", file)}}, nil
+	}
+
+	problems := ConfigProblems{}
+	from, _, err := migrateConfig(&root)
+	if err != nil {
+		return nil, ConfigProblems{{Message: err.Error()}}, nil
+	}
+	if from != version {
+		problems = append(problems, ConfigProblem{
+			Message: fmt.Sprintf("config version %s is out of date and was read as version %s, run `agent-p migrate %s` to update it", from, version, file),
+			warning: true,
+		})
+	}
+
+	err = prepareConfig(&root)
+	if err != nil {
+		return nil, append(problems, ConfigProblem{Message: err.Error()}), nil
+	}
+
+	problems = append(problems, unknownKeys(documentRoot(&root), reflect.TypeOf(RunConfig{}), "")...)
+
+	cfg := RunConfig{}
+	err = root.Decode(&cfg)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for _, message := range typeErr.Errors {
+			problems = append(problems, lineProblem(&root, message))
+		}
+	} else if err != nil {
+		problems = append(problems, ConfigProblem{Message: err.Error()})
+	}
+
+	// values that could not be decoded are left empty, the rest of the config is still checked
+	jobs := mappingValue(documentRoot(&root), "jobs")
+	for _, err := range cfg.defaultAndValidate() {
+		problem := ConfigProblem{Message: err.Error()}
+		var jobErr jobError
+		var fieldErr fieldError
+		hasField := errors.As(err, &fieldErr)
+		if errors.As(err, &jobErr) && jobs != nil && jobErr.job < len(jobs.Content) {
+			job := jobs.Content[jobErr.job]
+			problem.Line, problem.Column = jobPosition(job)
+			if hasField {
+				problem.Line, problem.Column = fieldPosition(job, fieldErr.field, problem.Line, problem.Column)
+			}
+			problem.Message = fmt.Sprintf("job %s: %s", cfg.Runs[jobErr.job].Name, problem.Message)
+		} else if hasField {
+			problem.Line, problem.Column = fieldPosition(documentRoot(&root), fieldErr.field, 0, 0)
+		}
+		problems = append(problems, problem)
+	}
+
+	return &cfg, problems.sorted(), nil
+}
+
+// errors are the problems that stop a config from being run
+func (p ConfigProblems) errors() ConfigProblems {
+	errs := ConfigProblems{}
+	for _, problem := range p {
+		if !problem.warning {
+			errs = append(errs, problem)
+		}
+	}
+	return errs
+}
+
+// warnings are the problems that are only warned about when a config is run
+func (p ConfigProblems) warnings() ConfigProblems {
+	warnings := ConfigProblems{}
+	for _, problem := range p {
+		if problem.warning {
+			warnings = append(warnings, problem)
+		}
+	}
+	return warnings
+}
+
+// sorted orders problems by where they are in the file, and drops the duplicates that jobs
+// expanded from the same matrix or template share
+func (p ConfigProblems) sorted() ConfigProblems {
+	sort.SliceStable(p, func(i, j int) bool {
+		if p[i].Line != p[j].Line {
+			return p[i].Line < p[j].Line
+		}
+		return p[i].Column < p[j].Column
+	})
+
+	unique := ConfigProblems{}
+	seen := map[string]bool{}
+	for _, problem := range p {
+		if seen[problem.String()] {
+			continue
+		}
+		seen[problem.String()] = true
+		unique = append(unique, problem)
+	}
+	return unique
+}
+
+// lineProblem turns a yaml error of the form "line 3: message" into a problem, looking up the
+// column of the first value on that line
+func lineProblem(root *yaml.Node, message string) ConfigProblem {
+	match := yamlErrorLine.FindStringSubmatch(message)
+	if match == nil {
+		return ConfigProblem{Message: message}
+	}
+
+	line, _ := strconv.Atoi(match[1])
+	problem := ConfigProblem{Line: line, Message: match[2]}
+	walkScalars(root, func(node *yaml.Node) error {
+		if node.Line == line && problem.Column == 0 {
+			problem.Column = node.Column
+		}
+		return nil
+	})
+	return problem
+}
+
+// jobPosition is where the name of a job is written, or where the job starts when it has no name
+func jobPosition(job *yaml.Node) (int, int) {
+	if name := mappingValue(job, "name"); name != nil {
+		return name.Line, name.Column
+	}
+	return job.Line, job.Column
+}
+
+// fieldPosition is where the key of a field is written in a mapping. When the field is not
+// written, it is the key of the closest section that is, or line and column when none are.
+func fieldPosition(node *yaml.Node, field string, line, column int) (int, int) {
+	for _, key := range strings.Split(field, ".") {
+		if node == nil || node.Kind != yaml.MappingNode {
+			break
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				line, column = node.Content[i].Line, node.Content[i].Column
+				value = node.Content[i+1]
+				break
+			}
+		}
+		if value == nil {
+			break
+		}
+		node = value
+	}
+	return line, column
+}
+
+// unknownKeys returns a problem for every key in the yaml that does not match a field of the type
+// it is decoded into
+func unknownKeys(node *yaml.Node, t reflect.Type, path string) ConfigProblems {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	problems := ConfigProblems{}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return problems
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			field, ok := fields[key.Value]
+			if !ok {
+				problems = append(problems, ConfigProblem{
+					Line:    key.Line,
+					Column:  key.Column,
+					Message: unknownKeyMessage(key.Value, path, fields),
+					warning: true,
+				})
+				continue
+			}
+			problems = append(problems, unknownKeys(node.Content[i+1], field.Type, joinPath(path, key.Value))...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return problems
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			problems = append(problems, unknownKeys(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return problems
+		}
+		for _, item := range node.Content {
+			problems = append(problems, unknownKeys(item, t.Elem(), path)...)
+		}
+	}
+	return problems
+}
+
+func unknownKeyMessage(key, path string, fields map[string]reflect.StructField) string {
+	message := fmt.Sprintf("unknown key %s", joinPath(path, key))
+	closest, distance := "", len(key)
+	for name := range fields {
+		d := editDistance(key, name)
+		if d < distance || (d == distance && name < closest) {
+			closest, distance = name, d
+		}
+	}
+	if closest != "" && distance <= 2 {
+		message += fmt.Sprintf(", did you mean %s?", closest)
+	}
+	return message
+}
+
+// yamlFields maps the yaml keys of a struct to its fields, the same way yaml decodes them
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			for k, v := range yamlFields(field.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// editDistance is the number of single character edits it takes to turn one string into another
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	config := `version: 0.2.0
+new-relic-server: prod
+new-relic-license-key: abc
+jobs:
+  - name: a
+    app:
+      image: my-app:latest
+      service-port: abc
+    traffic-driver:
+      traffic:
+        concurent-requests: 3
+  - name: b
+    exporter: foo
+    data:
+      collector: bar
+`
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(config), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, problems, err := ValidateConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []struct {
+		line    int
+		column  int
+		message string
+	}{
+		{2, 1, "new-relic-server must be either"},
+		{8, 21, "cannot unmarshal"},
+		{11, 9, "did you mean concurrent-requests?"},
+		{12, 11, "job b: config error: run.app.image"},
+		{13, 5, "job b: config error: run.exporter"},
+		{15, 7, "job b: config error: data.collector"},
+	}
+	if len(problems) != len(expect) {
+		t.Fatalf("expected %d problems, got %d:\n%s", len(expect), len(problems), problems.Error())
+	}
+	for i, e := range expect {
+		p := problems[i]
+		if p.Line != e.line || p.Column != e.column || !strings.Contains(p.Message, e.message) {
+			t.Errorf("expected problem %d to be at %d:%d containing %q, got %s", i, e.line, e.column, e.message, p)
+		}
+	}
+
+	if len(problems.warnings()) != 1 || !problems[2].warning {
+		t.Errorf("only the unknown key should be a warning, got %v", problems.warnings())
+	}
+}
+
+func TestValidateConfigFieldPositions(t *testing.T) {
+	config := `version: 0.2.0
+new-relic-server: local
+jobs:
+  - name: a
+    baseline: missing
+    app:
+      image: my-app:latest
+      service-port: 8000
+      runtime-metrics:
+        format: json
+    data:
+      collection-interval: soon
+    traffic-driver:
+      traffic:
+        duration: -1s
+`
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(config), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, problems, err := ValidateConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []struct {
+		line    int
+		column  int
+		message string
+	}{
+		{5, 5, "baseline missing"},
+		{10, 9, "runtime-metrics.format"},
+		{12, 7, "data.collection-interval"},
+		{15, 9, "traffic-driver.traffic.duration"},
+	}
+	if len(problems) != len(expect) {
+		t.Fatalf("expected %d problems, got %d:\n%s", len(expect), len(problems), problems.Error())
+	}
+	for i, e := range expect {
+		p := problems[i]
+		if p.Line != e.line || p.Column != e.column || !strings.Contains(p.Message, e.message) {
+			t.Errorf("expected problem %d to be at %d:%d containing %q, got %s", i, e.line, e.column, e.message, p)
+		}
+	}
+}
+
+func TestValidateConfigSectionProblems(t *testing.T) {
+	config := `version: 0.2.0
+new-relic-server: local
+jobs:
+  - name: a
+    app:
+      service-port: 8000
+      profiling:
+        cpu-duration: soon
+    data:
+      collection-interval: -1s
+      cooldown: later
+    traffic-driver:
+      startup-delay: never
+      traffic:
+        duration: 0
+`
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(config), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, problems, err := ValidateConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every problem in a section is reported, not only the first
+	expect := []struct {
+		line    int
+		column  int
+		message string
+	}{
+		{5, 5, "run.app.image"},
+		{8, 9, "profiling.cpu-duration"},
+		{10, 7, "data.collection-interval"},
+		{11, 7, "data.cooldown"},
+		{13, 7, "traffic-driver.startup-delay"},
+		{15, 9, "traffic-driver.traffic.duration"},
+	}
+	if len(problems) != len(expect) {
+		t.Fatalf("expected %d problems, got %d:\n%s", len(expect), len(problems), problems.Error())
+	}
+	for i, e := range expect {
+		p := problems[i]
+		if p.Line != e.line || p.Column != e.column || !strings.Contains(p.Message, e.message) {
+			t.Errorf("expected problem %d to be at %d:%d containing %q, got %s", i, e.line, e.column, e.message, p)
+		}
+	}
+}
+
+func TestValidateConfigSyntaxError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte("jobs:\n  - name: a\n    app: b\n     c: d\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, problems, err := ValidateConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Line != 4 {
+		t.Errorf("expected a syntax error on line 4, got %v", problems)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	if d := editDistance("concurent-requests", "concurrent-requests"); d != 1 {
+		t.Errorf("expected an edit distance of 1, got %d", d)
+	}
+	if d := editDistance("", "abc"); d != 3 {
+		t.Errorf("expected an edit distance of 3, got %d", d)
+	}
+}
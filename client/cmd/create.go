@@ -44,6 +44,26 @@ jobs if run multiple times from the same config.`,
 	},
 }
 
+var createSchema = &cobra.Command{
+	Use:   "schema [config.schema.json]",
+	Short: "Create the json schema of config files",
+	Long: `Generate the json schema that config files follow, so editors can complete and check
+them as you write them. This command can optionally take a name for that file, but when unspecified,
+it will create a file named config.schema.json in your working directory.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inputs.ShouldExit = false
+		inputs.Create = &Create{
+			Schema: true,
+		}
+		if len(args) == 0 {
+			inputs.Create.Config = defaultSchemaFileName
+		} else {
+			inputs.Create.Config = args[0]
+		}
+	},
+}
+
 // createCmd represents the create command
 var create = &cobra.Command{
 	Use:   "create",
@@ -58,6 +78,7 @@ func init() {
 	rootCmd.AddCommand(create)
 	create.AddCommand(createJobs)
 	create.AddCommand(createConfig)
+	create.AddCommand(createSchema)
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -0,0 +1,25 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+// historyInputs is bound to the flags of history before it is known to have been called
+var historyInputs = &History{}
+
+var history = &cobra.Command{
+	Use:   "history <job>",
+	Short: "Show how the p95 cpu and memory of a job changed across its runs.",
+	Long: `History reads every run of a job recorded in the results database of the jobs workspace, and lists
+the p95 cpu and memory of each run along with how much they changed since the run before it. Runs are labelled
+by the digest of the app image they ran, or by the version of agent-p that ran them.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inputs.History = historyInputs
+		inputs.History.Job = args[0]
+		inputs.ShouldExit = false
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(history)
+	history.Flags().StringVarP(&historyInputs.Label, "label", "l", "digest", "label runs by the app image \"digest\" or the agent-p \"version\"")
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+// migrateInputs is bound to the flags of migrate before it is known to have been called
+var migrateInputs = &Migrate{}
+
+var migrate = &cobra.Command{
+	Use:   "migrate [config.yaml]",
+	Short: "Rewrite a config file written for an older version of agent-p into the current format.",
+	Long: `Migrate reads the version of a config file, and applies every change to the config format made since
+that version, then sets the version to the current one. It rewrites the config file in place unless an output
+file is passed. It will look for a file named config.yaml or consume the config file if optionally passed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inputs.Migrate = migrateInputs
+		inputs.Migrate.Config = defaultConfigFileName
+		if len(args) > 0 {
+			inputs.Migrate.Config = args[0]
+		}
+		if inputs.Migrate.Output == "" {
+			inputs.Migrate.Output = inputs.Migrate.Config
+		}
+		inputs.ShouldExit = false
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrate)
+	migrate.Flags().StringVarP(&migrateInputs.Output, "output", "o", "", "write the migrated config to this file instead of rewriting the config file")
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+const defaultReportFileName = "report.html"
+
+// reportInputs is bound to the flags of report before it is known to have been called
+var reportInputs = &Report{}
+
+var report = &cobra.Command{
+	Use:   "report [config.yaml]",
+	Short: "Write a static html report of the latest run of each job in a config.",
+	Long: `Report reads the latest run of each job in a config file, and writes a single html file with tables
+of the summary statistics of each job, charts comparing the jobs, the config and manifest of each run, and how
+much each job changed from its baseline. The report has no external dependencies, so it can be attached to a
+pull request or ticket. It will look for a file named config.yaml or consume the config file if optionally passed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inputs.Report = reportInputs
+		inputs.Report.Config = defaultConfigFileName
+		if len(args) > 0 {
+			inputs.Report.Config = args[0]
+		}
+		inputs.ShouldExit = false
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(report)
+	report.Flags().StringVarP(&reportInputs.Output, "output", "o", defaultReportFileName, "write the report to this file")
+}
@@ -11,13 +11,40 @@ const (
 )
 
 type Inputs struct {
-	ShouldExit bool
-	Debug      bool
-	Silent     bool
-	CleanRun   bool
+	ShouldExit     bool
+	Debug          bool
+	Silent         bool
+	CleanRun       bool
+	Resume         bool
+	Markdown       string
+	Threshold      float64
+	MetricsAddress string
 	*Run
 	*Create
 	*Clean
+	*Validate
+	*Migrate
+	*History
+	*Report
+}
+
+type Report struct {
+	Config string
+	Output string
+}
+
+type History struct {
+	Job   string
+	Label string
+}
+
+type Validate struct {
+	Config string
+}
+
+type Migrate struct {
+	Config string
+	Output string
 }
 
 type Clean struct {
@@ -31,6 +58,7 @@ type Run struct {
 type Create struct {
 	Config string
 	Jobs   bool
+	Schema bool
 }
 
 var (
@@ -46,7 +74,7 @@ is up to no good in the tri-state area, Agent P comes to the rescue!
 	
 Agent P is a tool that simplifies the process of measuring the performance of an app
 that is being monitored by a language agent. This tool is completely idempotentent, and
-can be run multiple times. Note that running it will overwrite any collected data.`,
+can be run multiple times. Each run keeps its results in a new directory, so collected data is never overwritten.`,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
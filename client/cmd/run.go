@@ -7,6 +7,7 @@ import (
 const (
 	defaultJobsDir        = "jobs"
 	defaultConfigFileName = "config.yaml"
+	defaultSchemaFileName = "config.schema.json"
 )
 
 // runCmd represents the run command
@@ -14,7 +15,7 @@ var run = &cobra.Command{
 	Use:   "run [config.yaml]",
 	Short: "Run a batch of performance profiling jobs. Optionally pass a specific config file.",
 	Long: `Run generates, then runs the jobs outlined in the config file. It will collect metrics
-on those jobs and output it the created directory for a given job as a file called data.csv.`,
+on those jobs and output it to a new run directory of a given job as a file called data.csv.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		inputs.Run = &Run{}
@@ -30,4 +31,8 @@ on those jobs and output it the created directory for a given job as a file call
 func init() {
 	rootCmd.AddCommand(run)
 	run.Flags().BoolVarP(&inputs.CleanRun, "no-clean", "c", true, "do not clean up docker resources when run completes")
+	run.Flags().BoolVarP(&inputs.Resume, "resume", "r", false, "only run the jobs that do not already have complete results")
+	run.Flags().StringVar(&inputs.Markdown, "markdown", "", "write the markdown summary of the batch to this file instead of jobs/summary.md")
+	run.Flags().Float64Var(&inputs.Threshold, "regression-threshold", 10, "mark metrics that are worse than their baseline by more than this percent as regressions")
+	run.Flags().StringVarP(&inputs.MetricsAddress, "metrics-address", "m", "", "serve live job metrics in the prometheus format on this address, for example localhost:9090")
 }
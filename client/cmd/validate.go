@@ -0,0 +1,25 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var validate = &cobra.Command{
+	Use:   "validate [config.yaml]",
+	Short: "Check a config file for problems without running it.",
+	Long: `Validate reads a config file the same way run does, and reports every problem in it at once, with the
+line and column it is on. Unlike run, it also fails on keys it does not know, which are usually typos. It will look
+for a file named config.yaml or consume the config file if optionally passed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inputs.Validate = &Validate{}
+		inputs.ShouldExit = false
+		if len(args) == 0 {
+			inputs.Validate.Config = defaultConfigFileName
+		} else {
+			inputs.Validate.Config = args[0]
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validate)
+}
@@ -16,7 +16,7 @@ func InternalError(err error) {
 	os.Exit(1)
 }
 
-func DockerComposeError(composeCmd string) {
-	log.Info().Msgf("Docker compose exited with an error. To troubleshoot this, please run \"%s\".", composeCmd)
-	os.Exit(1)
+func Interrupted(err error) {
+	log.Warn().Msg(err.Error())
+	os.Exit(130)
 }
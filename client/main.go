@@ -4,9 +4,12 @@ import (
 	"agent-p/app"
 	"agent-p/cmd"
 	"agent-p/handle"
+	"context"
 	"errors"
-
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -15,7 +18,7 @@ import (
 func main() {
 	// Setup  App
 	log.Logger = zerolog.New(os.Stdout).Output(zerolog.ConsoleWriter{
-		Out:        os.Stdout,
+		Out:        app.Redact(os.Stdout),
 		TimeFormat: zerolog.TimeFormatUnix,
 		PartsExclude: []string{
 			zerolog.TimestampFieldName,
@@ -28,8 +31,31 @@ func main() {
 		os.Exit(0)
 	}
 
+	// the first SIGINT or SIGTERM cancels the run so it can clean up, a second one exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := run(ctx, inputs)
+	stop()
+	if err != nil {
+		if errors.Is(err, app.ErrInterrupted) {
+			handle.Interrupted(err)
+		}
+		if app.IsConfigError(err) {
+			handle.IncorrectUsage(err)
+		}
+		handle.InternalError(err)
+	}
+	os.Exit(0)
+}
+
+// run does what the command asked for, every error is returned here so main decides how to exit
+func run(ctx context.Context, inputs cmd.Inputs) error {
 	if inputs.Silent && inputs.Debug {
-		handle.IncorrectUsage(errors.New("application logs can not be both silent and printing debug logs"))
+		return app.ConfigError{Err: errors.New("application logs can not be both silent and printing debug logs")}
 	}
 
 	if inputs.Silent {
@@ -41,25 +67,102 @@ func main() {
 	// Main Body
 	if inputs.Create != nil {
 		configFile := inputs.Create.Config
-		if inputs.Create.Jobs {
+		if inputs.Create.Schema {
+			log.Debug().Msg("creating a config schema...")
+			return app.CreateSchema(configFile)
+		} else if inputs.Create.Jobs {
 			log.Debug().Msg("creating jobs...")
-			config := app.GetConfig(configFile)
-			config.CreateJobs()
-		} else {
-			log.Debug().Msg("creating a config...")
-			app.CreateConfig(configFile)
+			config, err := app.GetConfig(configFile)
+			if err != nil {
+				return err
+			}
+			_, err = config.CreateJobs()
+			return err
 		}
+		log.Debug().Msg("creating a config...")
+		return app.CreateConfig(configFile)
 	}
 	if inputs.Clean != nil {
 		log.Info().Msgf("Cleaning up jobs")
-		config := app.GetConfig(inputs.Clean.Config)
-		config.Clean()
+		config, err := app.GetConfig(inputs.Clean.Config)
+		if err != nil {
+			return err
+		}
+		return config.Clean()
+	}
+	if inputs.Validate != nil {
+		log.Debug().Msgf("validating config \"%s\"...", inputs.Validate.Config)
+		config, problems, err := app.ValidateConfig(inputs.Validate.Config)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				log.Error().Msgf("%s: %s", inputs.Validate.Config, problem)
+			}
+			return app.ConfigError{Err: fmt.Errorf("found %d problems in %s", len(problems), inputs.Validate.Config)}
+		}
+		log.Info().Msgf("%s is valid, it has %d jobs", inputs.Validate.Config, len(config.Runs))
+		return nil
+	}
+	if inputs.Migrate != nil {
+		changes, err := app.MigrateConfig(inputs.Migrate.Config, inputs.Migrate.Output)
+		if err != nil {
+			return app.ConfigError{Err: err}
+		}
+		if len(changes) == 0 {
+			log.Info().Msgf("%s is already up to date", inputs.Migrate.Config)
+			return nil
+		}
+		for _, change := range changes {
+			log.Info().Msgf("%s: %s", inputs.Migrate.Config, change)
+		}
+		log.Info().Msgf("wrote the migrated config to %s", inputs.Migrate.Output)
+		return nil
+	}
+	if inputs.History != nil {
+		return app.History(os.Stdout, inputs.History.Job, inputs.History.Label)
+	}
+	if inputs.Report != nil {
+		log.Debug().Msgf("writing a report of config \"%s\"...", inputs.Report.Config)
+		return app.Report(inputs.Report.Config, inputs.Report.Output)
 	}
 	if inputs.Run != nil {
 		log.Debug().Msgf("running from config \"%s\"...", inputs.Run.Config)
-		config := app.GetConfig(inputs.Run.Config)
-		jobs := config.CreateJobs()
-		jobs.Run(inputs.CleanRun)
+		config, err := app.GetConfig(inputs.Run.Config)
+		if err != nil {
+			return err
+		}
+		if inputs.MetricsAddress != "" {
+			err := app.ServeLiveMetrics(inputs.MetricsAddress)
+			if err != nil {
+				return app.ConfigError{Err: err}
+			}
+		}
+		jobs, err := config.CreateJobs()
+		if err != nil {
+			return err
+		}
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				log.Warn().Msg("interrupted, saving the data collected so far and cleaning up, interrupt again to exit immediately")
+			case <-done:
+			}
+		}()
+		err = jobs.Run(ctx, inputs.CleanRun, inputs.Resume)
+
+		markdown := inputs.Markdown
+		if markdown == "" {
+			markdown = app.MarkdownSummary()
+		}
+		summaryErr := jobs.WriteMarkdownSummary(markdown, inputs.Threshold)
+		if summaryErr != nil {
+			log.Warn().Msgf("unable to write the markdown summary of the batch: %v", summaryErr)
+		}
+		return err
 	}
-	os.Exit(0)
+	return nil
 }